@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"firebase.google.com/go/v4/auth"
 
@@ -29,18 +33,20 @@ var deleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete user",
 	PreRun: func(cmd *cobra.Command, args []string) {
-		checkEnvironment()
+		checkEnvironment(firebase.ClientAuth)
+		checkProduction(cmd, firebase.ClientAuth)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
+		client := authClient(ctx)
 		if uid := cmd.Flag("uid").Value.String(); uid != "" {
-			if err := firebase.Auth.DeleteUser(ctx, uid); err != nil {
+			if err := client.DeleteUser(ctx, uid); err != nil {
 				log.Fatalln(err)
 			}
 		} else if email := cmd.Flag("email").Value.String(); email != "" {
-			if rec, err := firebase.Auth.GetUserByEmail(ctx, email); err != nil {
+			if rec, err := client.GetUserByEmail(ctx, email); err != nil {
 				log.Fatalln(err)
-			} else if err := firebase.Auth.DeleteUser(ctx, rec.UID); err != nil {
+			} else if err := client.DeleteUser(ctx, rec.UID); err != nil {
 				log.Fatalln(err)
 			}
 		} else {
@@ -53,10 +59,12 @@ var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update user",
 	PreRun: func(cmd *cobra.Command, args []string) {
-		checkEnvironment()
+		checkEnvironment(firebase.ClientAuth)
+		checkProduction(cmd, firebase.ClientAuth)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
+		client := authClient(ctx)
 
 		var update = auth.UserToUpdate{}
 		if name := cmd.Flag("displayName").Value.String(); name != "" {
@@ -67,18 +75,18 @@ var updateCmd = &cobra.Command{
 		var rec *auth.UserRecord
 
 		if uid := cmd.Flag("uid").Value.String(); uid != "" {
-			if rec, err = firebase.Auth.GetUser(ctx, uid); err != nil {
+			if rec, err = client.GetUser(ctx, uid); err != nil {
 				log.Fatalln(err)
 			}
 		} else if email := cmd.Flag("email").Value.String(); email != "" {
-			if rec, err = firebase.Auth.GetUserByEmail(ctx, email); err != nil {
+			if rec, err = client.GetUserByEmail(ctx, email); err != nil {
 				log.Fatalln(err)
 			}
 		} else {
 			log.Fatalf("uid or email parameter is required")
 		}
 
-		if rec, err = firebase.Auth.UpdateUser(ctx, rec.UID, &update); err != nil {
+		if rec, err = client.UpdateUser(ctx, rec.UID, &update); err != nil {
 			log.Fatalln(err)
 		}
 
@@ -86,8 +94,57 @@ var updateCmd = &cobra.Command{
 	},
 }
 
-func checkEnvironment() {
-	if err := firebase.CheckEnvironment(); err != nil {
+// checkEnvironment initializes the clients of the kind, it exits if the service is
+// emulated, because the Admin SDK supports only the Firestore emulator
+func checkEnvironment(kind string) {
+	if clients != nil {
+		return
+	}
+	if emulators := firebase.GetEmulators(); emulators.IsEmulated(kind) && kind != firebase.ClientFirestore {
+		log.Fatalf("The %v emulator is not supported by the Admin SDK, unset the emulator variable to use the production project (%v)", kind, emulators)
+	}
+	opts := []firebase.ClientOption{firebase.WithEagerClients(kind)}
+	if credentials != "" {
+		opts = append(opts, firebase.WithCredentialsFile(credentials))
+	} else if err := firebase.CheckEnvironment(kind); err != nil {
+		log.Fatalln(err)
+	}
+	if project != "" {
+		opts = append(opts, firebase.WithProjectID(project))
+	}
+	var err error
+	if clients, err = firebase.NewClients(context.Background(), opts...); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func authClient(ctx context.Context) *auth.Client {
+	client, err := clients.GetAuth(ctx)
+	if err != nil {
 		log.Fatalln(err)
 	}
+	return client
+}
+
+// checkProduction asks for confirmation if the command would modify the
+// production project of the clients, it exits if it is not confirmed
+func checkProduction(cmd *cobra.Command, kind string) {
+	if confirmProduction || firebase.GetEmulators().IsEmulated(kind) {
+		return
+	}
+
+	target := clients.ProjectID()
+	if target == "" {
+		target = "of the credentials"
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		log.Fatalf("The %v command modifies the production project %v, use --confirm-production to run it", cmd.CommandPath(), target)
+	}
+
+	fmt.Printf("The %v command modifies the production project %v. Continue? [y/N] ", cmd.CommandPath(), target)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		log.Fatalln("Cancelled")
+	}
 }
@@ -5,16 +5,30 @@ import (
 
 	homedir "github.com/mitchellh/go-homedir"
 
+	"github.com/balesz/go/firebase"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// clients are the Firebase clients of the commands, they are initialized by the commands if not set
+var clients *firebase.Clients
+
+// credentials and project override the environment of the Firebase clients
+var credentials, project string
+
+// confirmProduction allows the destructive commands against the production project without prompt
+var confirmProduction bool
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cli.yaml)")
+	rootCmd.PersistentFlags().StringVar(&credentials, "credentials", "", "service account file (default is $GOOGLE_APPLICATION_CREDENTIALS)")
+	rootCmd.PersistentFlags().StringVar(&project, "project", "", "Firebase project ID (default is the project of $FIREBASE_CONFIG)")
+	rootCmd.PersistentFlags().BoolVar(&confirmProduction, "confirm-production", false, "run destructive commands against the production project without prompt")
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -29,6 +43,12 @@ func Execute() {
 	}
 }
 
+// ExecuteWithClients executes the root command with the given Firebase clients
+func ExecuteWithClients(firebaseClients *firebase.Clients) {
+	clients = firebaseClients
+	Execute()
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
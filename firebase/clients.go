@@ -0,0 +1,321 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	firestore "cloud.google.com/go/firestore"
+	firebase "firebase.google.com/go/v4"
+	auth "firebase.google.com/go/v4/auth"
+	database "firebase.google.com/go/v4/db"
+	"google.golang.org/api/option"
+)
+
+// Kinds of the Firebase clients
+const (
+	ClientAuth      = "auth"
+	ClientDatabase  = "database"
+	ClientFirestore = "firestore"
+)
+
+// defaultMutex guards the lazy initialization of the package-level variables
+var defaultMutex sync.Mutex
+
+// Clients holds a Firebase app and its clients, so more projects can be used
+// in one process and the clients can be substituted in tests. The clients which
+// are not initialized by NewClients are initialized on the first use of their getter.
+type Clients struct {
+	App       *firebase.App
+	Auth      *auth.Client
+	Database  *database.Client
+	Firestore *firestore.Client
+
+	config          *firebase.Config
+	isDefault       bool
+	mutex           sync.Mutex
+	unauthenticated bool
+}
+
+// ClientOption configures the Firebase app of NewClients
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	config  firebase.Config
+	eager   []string
+	options []option.ClientOption
+}
+
+// WithConfig sets the config of the app, the default or nil is the config of FIREBASE_CONFIG
+func WithConfig(config *firebase.Config) ClientOption {
+	return func(opts *clientOptions) {
+		if config != nil {
+			opts.config = *config
+		}
+	}
+}
+
+// WithProjectID sets the project of the app
+func WithProjectID(projectID string) ClientOption {
+	return func(opts *clientOptions) {
+		opts.config.ProjectID = projectID
+	}
+}
+
+// WithDatabaseURL sets the URL of the Realtime Database
+func WithDatabaseURL(url string) ClientOption {
+	return func(opts *clientOptions) {
+		opts.config.DatabaseURL = url
+	}
+}
+
+// WithStorageBucket sets the default Cloud Storage bucket
+func WithStorageBucket(bucket string) ClientOption {
+	return func(opts *clientOptions) {
+		opts.config.StorageBucket = bucket
+	}
+}
+
+// WithServiceAccountID sets the service account which signs the custom tokens
+func WithServiceAccountID(serviceAccountID string) ClientOption {
+	return func(opts *clientOptions) {
+		opts.config.ServiceAccountID = serviceAccountID
+	}
+}
+
+// WithCredentialsFile sets the service account file, the default is GOOGLE_APPLICATION_CREDENTIALS
+func WithCredentialsFile(path string) ClientOption {
+	return WithClientOptions(option.WithCredentialsFile(path))
+}
+
+// WithCredentialsJSON sets the content of the service account file
+func WithCredentialsJSON(credentials []byte) ClientOption {
+	return WithClientOptions(option.WithCredentialsJSON(credentials))
+}
+
+// WithClientOptions adds options of the Google API clients
+func WithClientOptions(options ...option.ClientOption) ClientOption {
+	return func(opts *clientOptions) {
+		opts.options = append(opts.options, options...)
+	}
+}
+
+// WithEagerClients sets the kinds of the clients (ClientAuth, ClientDatabase,
+// ClientFirestore) initialized by NewClients, the others are initialized on their
+// first use. Every client is initialized by default, none without kinds.
+func WithEagerClients(kinds ...string) ClientOption {
+	return func(opts *clientOptions) {
+		opts.eager = append([]string{}, kinds...)
+	}
+}
+
+// NewClients initializes a Firebase app and its clients. The options override
+// the config of FIREBASE_CONFIG. With emulators the project may be set by
+// GOOGLE_CLOUD_PROJECT or GCLOUD_PROJECT. The credentials are not required only
+// if every eager client is emulated and no credentials are given, otherwise the
+// Application Default Credentials are used as without emulators.
+func NewClients(ctx context.Context, opts ...ClientOption) (*Clients, error) {
+	config, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	options := clientOptions{config: *config, eager: []string{ClientAuth, ClientDatabase, ClientFirestore}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	emulators := GetEmulators()
+	if emulators.Any() && options.config.ProjectID == "" {
+		options.config.ProjectID = ProjectID()
+	}
+	unauthenticated := emulators.AllEmulated(options.eager...) &&
+		len(options.options) == 0 && os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == ""
+	if unauthenticated {
+		options.options = []option.ClientOption{option.WithoutAuthentication()}
+	}
+
+	app, err := firebase.NewApp(ctx, &options.config, options.options...)
+	if err != nil {
+		return nil, fmt.Errorf("firebase.NewApp: %v", err)
+	}
+	clients := &Clients{App: app, config: &options.config, unauthenticated: unauthenticated}
+	for _, kind := range options.eager {
+		if emulators.IsEmulated(kind) && kind != ClientFirestore {
+			// the Admin SDK supports only the Firestore emulator, the others fail on their first use
+			continue
+		}
+		switch kind {
+		case ClientAuth:
+			_, err = clients.GetAuth(ctx)
+		case ClientDatabase:
+			_, err = clients.GetDatabase(ctx)
+		case ClientFirestore:
+			_, err = clients.GetFirestore(ctx)
+		default:
+			err = fmt.Errorf("Unknown client kind (%v)", kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return clients, nil
+}
+
+// DefaultClients gets the clients of the package-level variables, which are set
+// by InitializeClients. The lazy clients are stored in the package-level variables.
+func DefaultClients() *Clients {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return &Clients{App: App, Auth: Auth, Database: Database, Firestore: Firestore, isDefault: true}
+}
+
+// Resolve returns the clients, or the default clients if it is nil
+func (clients *Clients) Resolve() *Clients {
+	if clients == nil {
+		return DefaultClients()
+	}
+	return clients
+}
+
+// ProjectID gets the project of the config of the app, empty if the project is set
+// only by the credentials or the clients are not created by NewClients
+func (clients *Clients) ProjectID() string {
+	if clients == nil || clients.config == nil {
+		return ""
+	}
+	return clients.config.ProjectID
+}
+
+// GetAuth gets the Auth client, it is initialized on the first call if necessary
+func (clients *Clients) GetAuth(ctx context.Context) (*auth.Client, error) {
+	clients.lock()
+	defer clients.unlock()
+	if clients.isDefault && Auth != nil {
+		clients.Auth = Auth
+	}
+	if clients.Auth != nil {
+		return clients.Auth, nil
+	} else if clients.App == nil {
+		return nil, fmt.Errorf("Initializing the Auth client failed (the Firebase app is not initialized)")
+	} else if host := os.Getenv(AuthEmulatorHost); host != "" {
+		return nil, fmt.Errorf("Initializing the Auth client failed (the Auth emulator at %v is not supported "+
+			"by the Admin SDK, the client would use the production project)", host)
+	} else if clients.unauthenticated {
+		return nil, fmt.Errorf("Initializing the Auth client failed (%v)", errUnauthenticated)
+	}
+	client, err := clients.App.Auth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Initializing the Auth client failed (App.Auth: %v)", err)
+	}
+	clients.Auth = client
+	if clients.isDefault {
+		Auth = client
+	}
+	return client, nil
+}
+
+// GetDatabase gets the Realtime Database client, it is initialized on the first call if necessary
+func (clients *Clients) GetDatabase(ctx context.Context) (*database.Client, error) {
+	clients.lock()
+	defer clients.unlock()
+	if clients.isDefault && Database != nil {
+		clients.Database = Database
+	}
+	if clients.Database != nil {
+		return clients.Database, nil
+	} else if clients.App == nil {
+		return nil, fmt.Errorf("Initializing the Database client failed (the Firebase app is not initialized)")
+	} else if host := os.Getenv(DatabaseEmulatorHost); host != "" {
+		return nil, fmt.Errorf("Initializing the Database client failed (the Database emulator at %v is not "+
+			"supported by the Admin SDK, the client would use the production database)", host)
+	} else if clients.unauthenticated {
+		return nil, fmt.Errorf("Initializing the Database client failed (%v)", errUnauthenticated)
+	} else if clients.config != nil && clients.config.DatabaseURL == "" {
+		return nil, fmt.Errorf("Initializing the Database client failed (the database URL is not configured, " +
+			"set databaseURL of FIREBASE_CONFIG or use WithDatabaseURL)")
+	}
+	client, err := clients.App.Database(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Initializing the Database client failed (App.Database: %v)", err)
+	}
+	clients.Database = client
+	if clients.isDefault {
+		Database = client
+	}
+	return client, nil
+}
+
+// GetFirestore gets the Firestore client, it is initialized on the first call if necessary
+func (clients *Clients) GetFirestore(ctx context.Context) (*firestore.Client, error) {
+	clients.lock()
+	defer clients.unlock()
+	if clients.isDefault && Firestore != nil {
+		clients.Firestore = Firestore
+	}
+	if clients.Firestore != nil {
+		return clients.Firestore, nil
+	} else if clients.App == nil {
+		return nil, fmt.Errorf("Initializing the Firestore client failed (the Firebase app is not initialized)")
+	} else if clients.unauthenticated && os.Getenv(FirestoreEmulatorHost) == "" {
+		return nil, fmt.Errorf("Initializing the Firestore client failed (%v)", errUnauthenticated)
+	}
+	client, err := clients.App.Firestore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Initializing the Firestore client failed (App.Firestore: %v)", err)
+	}
+	clients.Firestore = client
+	if clients.isDefault {
+		Firestore = client
+	}
+	return client, nil
+}
+
+// errUnauthenticated is the reason of the failure of the clients which are not emulated, when the app has no credentials
+var errUnauthenticated = fmt.Errorf("the app has no credentials, because only emulated clients are initialized " +
+	"eagerly, add the client to WithEagerClients or set the credentials")
+
+func (clients *Clients) lock() {
+	if clients.isDefault {
+		defaultMutex.Lock()
+	} else {
+		clients.mutex.Lock()
+	}
+}
+
+func (clients *Clients) unlock() {
+	if clients.isDefault {
+		defaultMutex.Unlock()
+	} else {
+		clients.mutex.Unlock()
+	}
+}
+
+// configFromEnv reads the config of FIREBASE_CONFIG, which is a JSON or the path of a JSON file
+func configFromEnv() (*firebase.Config, error) {
+	config := &firebase.Config{}
+	value := os.Getenv("FIREBASE_CONFIG")
+	if value == "" {
+		return config, nil
+	}
+	data := []byte(value)
+	if value[0] != '{' {
+		var err error
+		if data, err = ioutil.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("Reading FIREBASE_CONFIG failed (%v)", err)
+		}
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Parsing FIREBASE_CONFIG failed (%v)", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err == nil {
+		if override, ok := fields["databaseAuthVariableOverride"]; ok && override == nil {
+			var nullMap map[string]interface{}
+			config.AuthOverride = &nullMap
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,93 @@
+package firebase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables of the Firebase emulators
+const (
+	AuthEmulatorHost      = "FIREBASE_AUTH_EMULATOR_HOST"
+	DatabaseEmulatorHost  = "FIREBASE_DATABASE_EMULATOR_HOST"
+	FirestoreEmulatorHost = "FIRESTORE_EMULATOR_HOST"
+)
+
+// Emulators reports the hosts of the configured emulators, empty if the service is not emulated
+type Emulators struct {
+	Auth      string
+	Database  string
+	Firestore string
+}
+
+// GetEmulators gets the emulators of the environment
+func GetEmulators() Emulators {
+	return Emulators{
+		Auth:      os.Getenv(AuthEmulatorHost),
+		Database:  os.Getenv(DatabaseEmulatorHost),
+		Firestore: os.Getenv(FirestoreEmulatorHost),
+	}
+}
+
+// Any reports whether any service is emulated
+func (emulators Emulators) Any() bool {
+	return emulators.Auth != "" || emulators.Database != "" || emulators.Firestore != ""
+}
+
+// IsEmulated reports whether the service (ClientAuth, ClientDatabase or ClientFirestore) is emulated
+func (emulators Emulators) IsEmulated(kind string) bool {
+	switch kind {
+	case ClientAuth:
+		return emulators.Auth != ""
+	case ClientDatabase:
+		return emulators.Database != ""
+	case ClientFirestore:
+		return emulators.Firestore != ""
+	}
+	return false
+}
+
+// AllEmulated reports whether every service of the kinds is emulated, false without kinds
+func (emulators Emulators) AllEmulated(kinds ...string) bool {
+	for _, kind := range kinds {
+		if !emulators.IsEmulated(kind) {
+			return false
+		}
+	}
+	return len(kinds) > 0
+}
+
+func (emulators Emulators) String() string {
+	var services []string
+	for _, service := range []struct{ name, host string }{
+		{"Auth", emulators.Auth}, {"Database", emulators.Database}, {"Firestore", emulators.Firestore},
+	} {
+		if service.host != "" {
+			services = append(services, fmt.Sprintf("%v: %v", service.name, service.host))
+		}
+	}
+	if len(services) == 0 {
+		return "Emulators { none }"
+	}
+	return fmt.Sprintf("Emulators { %v }", strings.Join(services, ", "))
+}
+
+// IsEmulator reports whether any Firebase emulator is configured in the environment
+func IsEmulator() bool {
+	return GetEmulators().Any()
+}
+
+// ProjectID gets the project of the environment from FIREBASE_CONFIG, GOOGLE_CLOUD_PROJECT,
+// GCLOUD_PROJECT or GCP_PROJECT in this order, empty if it is not set. Every package of
+// the module uses this order for the project of the environment.
+func ProjectID() string {
+	if config, err := configFromEnv(); err == nil && config.ProjectID != "" {
+		return config.ProjectID
+	}
+	for _, key := range []string{"GOOGLE_CLOUD_PROJECT", "GCLOUD_PROJECT", "GCP_PROJECT"} {
+		if val := os.Getenv(key); val != "" {
+			return val
+		}
+	}
+	return ""
+}
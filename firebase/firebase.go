@@ -24,34 +24,36 @@ var Database *database.Client
 // Firestore is the default Firestore instance
 var Firestore *firestore.Client
 
-// InitializeClients initialize Firebase app and clients
-func InitializeClients() error {
+// InitializeClients initialize Firebase app and clients of the package-level
+// variables, the clients which are not initialized eagerly (see WithEagerClients)
+// are set on the first use of the getters of DefaultClients
+func InitializeClients(opts ...ClientOption) error {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
 	if App != nil {
 		return nil
 	}
-	var err error
-	ctx := context.Background()
-	App, err = firebase.NewApp(ctx, nil)
+	clients, err := NewClients(context.Background(), opts...)
 	if err != nil {
-		return fmt.Errorf("firebase.NewApp: %v", err)
-	}
-	Auth, err = App.Auth(ctx)
-	if err != nil {
-		return fmt.Errorf("App.Auth: %v", err)
-	}
-	Firestore, err = App.Firestore(ctx)
-	if err != nil {
-		return fmt.Errorf("App.Firestore: %v", err)
-	}
-	Database, err = App.Database(ctx)
-	if err != nil {
-		return fmt.Errorf("App.Database: %v", err)
+		return err
 	}
+	App, Auth, Database, Firestore = clients.App, clients.Auth, clients.Database, clients.Firestore
 	return nil
 }
 
-// CheckEnvironment checks the environment
-func CheckEnvironment() error {
+// CheckEnvironment checks the environment for the kinds of the used clients, the
+// default is every client. If every used client is emulated the credentials are
+// not required and the project may be set by GOOGLE_CLOUD_PROJECT or GCLOUD_PROJECT.
+func CheckEnvironment(kinds ...string) error {
+	if len(kinds) == 0 {
+		kinds = []string{ClientAuth, ClientDatabase, ClientFirestore}
+	}
+	if GetEmulators().AllEmulated(kinds...) {
+		if ProjectID() == "" {
+			return fmt.Errorf("The project of the emulators not found (set FIREBASE_CONFIG or GCLOUD_PROJECT)")
+		}
+		return nil
+	}
 	if os.Getenv("FIREBASE_CONFIG") == "" {
 		return fmt.Errorf("FIREBASE_CONFIG environment not found")
 	}
@@ -61,12 +63,16 @@ func CheckEnvironment() error {
 	return nil
 }
 
-// DBPath creates a valid Realtime Database path from the given parts
+// DBPath joins the parts to a Realtime Database path with leading slash.
+//
+// Deprecated: the parts are not validated, use DocPath.DBRef or CollectionPath.DBRef.
 func DBPath(parts ...string) string {
 	return "/" + strings.Join(parts, "/")
 }
 
-// FSPath creates a valid Realtime Database path from the given parts
+// FSPath joins the parts to a Firestore path.
+//
+// Deprecated: the parts are not validated, use NewDocPath or NewCollectionPath.
 func FSPath(parts ...string) string {
 	return strings.Join(parts, "/")
 }
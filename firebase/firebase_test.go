@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"regexp"
 	"testing"
@@ -9,21 +10,30 @@ import (
 	"github.com/balesz/go/env"
 )
 
+// errEnvironment is the error of the initialization, the integration tests are skipped if it is set
+var errEnvironment error
+
 func init() {
 	env.Init("game", "../.env")
-	err := CheckEnvironment()
-	if err != nil {
-		log.Fatalf("firebase.CheckEnvironment: %v", err)
+	if err := CheckEnvironment(); err != nil {
+		errEnvironment = fmt.Errorf("firebase.CheckEnvironment: %v", err)
+	} else if err := InitializeClients(); err != nil {
+		errEnvironment = fmt.Errorf("firebase.InitializeClients: %v", err)
 	}
-	err = InitializeClients()
-	if err != nil {
-		log.Fatalf("firebase.InitializeClients: %v", err)
+}
+
+// skipWithoutEnvironment skips the integration tests if the clients are not initialized
+func skipWithoutEnvironment(test *testing.T) {
+	if errEnvironment != nil {
+		test.Skip(errEnvironment)
 	}
 }
 
 func TestMisc(test *testing.T) {}
 
 func xTestFirestore(test *testing.T) {
+	skipWithoutEnvironment(test)
+
 	const path = "test/test"
 	ctx := context.Background()
 
@@ -44,6 +54,8 @@ func xTestFirestore(test *testing.T) {
 }
 
 func xTestRealtimeDatabase(test *testing.T) {
+	skipWithoutEnvironment(test)
+
 	const path = "test/test"
 	ctx := context.Background()
 
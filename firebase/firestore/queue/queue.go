@@ -2,10 +2,9 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
-	"strings"
 
 	"cloud.google.com/go/firestore"
 	"github.com/balesz/go/firebase"
@@ -13,33 +12,28 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// New creates a new queue
-func New(statePath string, forceRunPath string) (queue Queue, err error) {
-	var pathRegexp = regexp.MustCompile(`^\w+(?:/[\w\$\-]+)*$`)
+// Option configures the queue
+type Option func(*Queue)
 
-	if statePath == "" {
-		err = fmt.Errorf("The statePath parameter is empty")
-		return
-	} else if !pathRegexp.MatchString(statePath) {
-		err = fmt.Errorf("The statePath parameter is invalid")
-		return
-	} else if len(strings.Split(strings.TrimSpace(strings.Trim(statePath, "/")), "/"))%2 != 0 {
-		err = fmt.Errorf("The statePath parameter is not a document path")
-		return
+// WithClients sets the Firebase clients of the queue, the default is firebase.DefaultClients
+func WithClients(clients *firebase.Clients) Option {
+	return func(queue *Queue) {
+		queue.clients = clients
 	}
+}
 
-	if forceRunPath == "" {
-		err = fmt.Errorf("The forceRunPath parameter is empty")
-		return
-	} else if !pathRegexp.MatchString(forceRunPath) {
-		err = fmt.Errorf("The forceRunPath parameter is invalid")
+// New creates a new queue
+func New(statePath string, forceRunPath string, opts ...Option) (queue Queue, err error) {
+	if statePath, err = parsePath("statePath", statePath); err != nil {
 		return
-	} else if len(strings.Split(strings.TrimSpace(strings.Trim(forceRunPath, "/")), "/"))%2 != 0 {
-		err = fmt.Errorf("The forceRunPath parameter is not a document path")
+	} else if forceRunPath, err = parsePath("forceRunPath", forceRunPath); err != nil {
 		return
 	}
 
 	queue = Queue{ForceRunPath: forceRunPath, StatePath: statePath}
+	for _, opt := range opts {
+		opt(&queue)
+	}
 
 	return
 }
@@ -83,9 +77,14 @@ func (task Task) Dispatch(ctx context.Context) (err error) {
 }
 
 func (task Task) start(ctx context.Context) error {
+	client, err := task.queue.firestore(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
-		stateRef    = firebase.Firestore.Doc(task.queue.StatePath)
-		forceRunRef = firebase.Firestore.Doc(task.queue.ForceRunPath)
+		stateRef    = client.Doc(task.queue.StatePath)
+		forceRunRef = client.Doc(task.queue.ForceRunPath)
 		maxAttempts = firestore.MaxAttempts(1)
 	)
 
@@ -126,12 +125,17 @@ func (task Task) start(ctx context.Context) error {
 		})
 	}
 
-	return firebase.Firestore.RunTransaction(ctx, transaction, maxAttempts)
+	return client.RunTransaction(ctx, transaction, maxAttempts)
 }
 
 func (task Task) handle(ctx context.Context) error {
+	client, err := task.queue.firestore(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
-		stateRef    = firebase.Firestore.Doc(task.queue.StatePath)
+		stateRef    = client.Doc(task.queue.StatePath)
 		maxAttempts = firestore.MaxAttempts(5)
 	)
 
@@ -156,12 +160,17 @@ func (task Task) handle(ctx context.Context) error {
 		return task.worker.Execute(ctx, tran)
 	}
 
-	return firebase.Firestore.RunTransaction(ctx, transaction, maxAttempts)
+	return client.RunTransaction(ctx, transaction, maxAttempts)
 }
 
 func (task Task) stop(ctx context.Context) error {
+	client, err := task.queue.firestore(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
-		stateRef    = firebase.Firestore.Doc(task.queue.StatePath)
+		stateRef    = client.Doc(task.queue.StatePath)
 		maxAttempts = firestore.MaxAttempts(5)
 	)
 
@@ -188,12 +197,17 @@ func (task Task) stop(ctx context.Context) error {
 		})
 	}
 
-	return firebase.Firestore.RunTransaction(ctx, transaction, maxAttempts)
+	return client.RunTransaction(ctx, transaction, maxAttempts)
 }
 
 func (task Task) forceRun(ctx context.Context) error {
+	client, err := task.queue.firestore(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
-		stateRef    = firebase.Firestore.Doc(task.queue.StatePath)
+		stateRef    = client.Doc(task.queue.StatePath)
 		maxAttempts = firestore.MaxAttempts(2)
 	)
 
@@ -227,5 +241,23 @@ func (task Task) forceRun(ctx context.Context) error {
 		})
 	}
 
-	return firebase.Firestore.RunTransaction(ctx, transaction, maxAttempts)
+	return client.RunTransaction(ctx, transaction, maxAttempts)
+}
+
+func (queue Queue) firestore(ctx context.Context) (*firestore.Client, error) {
+	return queue.clients.Resolve().GetFirestore(ctx)
+}
+
+// parsePath checks whether the parameter is a valid document path and returns the parsed path
+func parsePath(name string, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("The %v parameter is empty", name)
+	}
+	doc, err := firebase.ParseDocPath(path)
+	if errors.Is(err, firebase.ErrNotDocumentPath) {
+		return "", fmt.Errorf("The %v parameter is not a document path", name)
+	} else if err != nil {
+		return "", fmt.Errorf("The %v parameter is invalid", name)
+	}
+	return doc.String(), nil
 }
@@ -3,6 +3,7 @@ package queue
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 
 	"cloud.google.com/go/firestore"
@@ -41,6 +42,12 @@ func TestNew(t *testing.T) {
 	if _, got := New("test/test/test", ""); want != got.Error() {
 		t.Errorf("%v != %v", want, got)
 	}
+	want = "test/state"
+	if queue, err := New("test/state", "test/forceRun"); err != nil {
+		t.Error(err)
+	} else if want != queue.StatePath {
+		t.Errorf("%v != %v", want, queue.StatePath)
+	}
 }
 
 func TestStart(t *testing.T) {
@@ -109,3 +116,27 @@ func (handler mockHandler) Execute(ctx context.Context, tran *firestore.Transact
 func (handler mockHandler) NeedForceExec(ctx context.Context, tran *firestore.Transaction) error {
 	return fmt.Errorf("no need to force execute")
 }
+
+func TestWithClients(t *testing.T) {
+	os.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	defer os.Unsetenv("FIRESTORE_EMULATOR_HOST")
+
+	client, err := firestore.NewClient(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if queue, err := New("queue/state", "queue/forceRun", WithClients(&firebase.Clients{Firestore: client})); err != nil {
+		t.Error(err)
+	} else if got, err := queue.firestore(context.Background()); err != nil || got != client {
+		t.Errorf("firestore: %v %v", got, err)
+	}
+
+	firebase.Firestore = client
+	defer func() { firebase.Firestore = nil }()
+	queue, _ := New("queue/state", "queue/forceRun")
+	if got, err := queue.firestore(context.Background()); err != nil || got != client {
+		t.Errorf("default firestore: %v %v", got, err)
+	}
+}
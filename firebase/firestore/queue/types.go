@@ -5,12 +5,14 @@ import (
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"github.com/balesz/go/firebase"
 )
 
 // Queue is the struct of the queue
 type Queue struct {
 	ForceRunPath string
 	StatePath    string
+	clients      *firebase.Clients
 }
 
 // Task is the struct of the queue processor
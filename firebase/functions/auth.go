@@ -0,0 +1,87 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+// AuthTriggerCreate : Triggered when a user account is created.
+const AuthTriggerCreate = "providers/firebase.auth/eventTypes/user.create"
+
+// AuthTriggerDelete : Triggered when a user account is deleted.
+const AuthTriggerDelete = "providers/firebase.auth/eventTypes/user.delete"
+
+// AuthEvent is the user record of an Authentication trigger
+type AuthEvent struct {
+	CustomClaims  map[string]interface{} `json:"customClaims,omitempty"`
+	Disabled      bool                   `json:"disabled"`
+	DisplayName   string                 `json:"displayName"`
+	Email         string                 `json:"email"`
+	EmailVerified bool                   `json:"emailVerified"`
+	Metadata      AuthEventMetadata      `json:"metadata"`
+	PhoneNumber   string                 `json:"phoneNumber"`
+	PhotoURL      string                 `json:"photoURL"`
+	ProviderData  []AuthEventProvider    `json:"providerData"`
+	UID           string                 `json:"uid"`
+}
+
+func (event AuthEvent) String() string {
+	format := "AuthEvent(UID: %v, Email: %v, DisplayName: %v, Metadata: %v)"
+	return fmt.Sprintf(format, event.UID, event.Email, event.DisplayName, event.Metadata)
+}
+
+// AuthEventMetadata is the metadata of the user record
+type AuthEventMetadata struct {
+	CreatedAt      time.Time `json:"createdAt"`
+	LastSignedInAt time.Time `json:"lastSignedInAt"`
+}
+
+func (meta AuthEventMetadata) String() string {
+	format := "AuthEventMetadata(CreatedAt: %v, LastSignedInAt: %v)"
+	return fmt.Sprintf(format, meta.CreatedAt, meta.LastSignedInAt)
+}
+
+// AuthEventProvider is a linked sign-in provider of the user record
+type AuthEventProvider struct {
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+	PhotoURL    string `json:"photoURL"`
+	ProviderID  string `json:"providerId"`
+	UID         string `json:"uid"`
+}
+
+// ParseAuthEvent decodes the payload of an Authentication trigger
+func ParseAuthEvent(ctx context.Context, payload []byte) (event AuthEvent, err error) {
+	if err = checkTrigger(ctx, AuthTriggerCreate, AuthTriggerDelete); err != nil {
+		return
+	} else if err = json.Unmarshal(payload, &event); err != nil {
+		err = fmt.Errorf("ParseAuthEvent: %v", err)
+	}
+	return
+}
+
+// MockAuthEvent MockAuthEvent
+type MockAuthEvent struct {
+	Trigger string
+	User    AuthEvent
+}
+
+// CreateContext creates context for testing
+func (evnt *MockAuthEvent) CreateContext(base context.Context) (ctx context.Context, event AuthEvent) {
+	event = evnt.User
+	if event.Metadata.CreatedAt.IsZero() {
+		event.Metadata.CreatedAt = time.Now()
+	}
+	ctx = metadata.NewContext(base, &metadata.Metadata{
+		EventID:   time.Now().UTC().Format(time.RFC3339),
+		EventType: evnt.Trigger,
+		Resource:  &metadata.Resource{RawPath: "projects/" + projectID()},
+		Timestamp: time.Now(),
+	})
+	return
+}
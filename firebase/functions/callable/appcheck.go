@@ -0,0 +1,213 @@
+package callable
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AppCheckJWKSURL is the URL of the public keys of the App Check tokens
+const AppCheckJWKSURL = "https://firebaseappcheck.googleapis.com/v1/jwks"
+
+const appCheckIssuer = "https://firebaseappcheck.googleapis.com/"
+
+// App Check statuses of the request
+const (
+	AppCheckMissing = "missing"
+	AppCheckInvalid = "invalid"
+	AppCheckValid   = "valid"
+)
+
+// AppCheck is the result of the App Check verification of the request
+type AppCheck struct {
+	AppID  string
+	Claims map[string]interface{}
+	Status string
+}
+
+func (it AppCheck) String() string {
+	return fmt.Sprintf("AppCheck { AppID: %v, Status: %v }", it.AppID, it.Status)
+}
+
+// AppCheckToken is a verified App Check token
+type AppCheckToken struct {
+	AppID   string
+	Claims  map[string]interface{}
+	Expires time.Time
+}
+
+// AppCheckVerifier verifies the token of the X-Firebase-AppCheck header
+type AppCheckVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*AppCheckToken, error)
+}
+
+// JWKSAppCheckVerifier verifies App Check tokens with the public keys of the JWKS endpoint
+type JWKSAppCheckVerifier struct {
+	HTTPClient *http.Client
+	JWKSURL    string
+	// Projects are the accepted project IDs or numbers of the token audience
+	Projects []string
+
+	expires time.Time
+	keys    map[string]*rsa.PublicKey
+	mutex   sync.Mutex
+}
+
+// NewAppCheckVerifier creates a verifier for the tokens of the given projects
+func NewAppCheckVerifier(projects ...string) *JWKSAppCheckVerifier {
+	return &JWKSAppCheckVerifier{HTTPClient: http.DefaultClient, JWKSURL: AppCheckJWKSURL, Projects: projects}
+}
+
+// VerifyToken verifies the signature, the issuer, the audience and the expiration of the token
+func (verifier *JWKSAppCheckVerifier) VerifyToken(ctx context.Context, token string) (*AppCheckToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("The App Check token is malformed")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("The App Check token header is invalid (%v)", err)
+	} else if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("The App Check token has invalid algorithm (%v)", header.Algorithm)
+	}
+
+	key, err := verifier.publicKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("The App Check token signature is invalid (%v)", err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, fmt.Errorf("The App Check token signature is invalid (%v)", err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("The App Check token payload is invalid (%v)", err)
+	}
+
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	expires, _ := claims["exp"].(float64)
+	if !strings.HasPrefix(issuer, appCheckIssuer) {
+		return nil, fmt.Errorf("The App Check token has invalid issuer (%v)", issuer)
+	} else if subject == "" {
+		return nil, fmt.Errorf("The App Check token has no subject")
+	} else if time.Now().After(time.Unix(int64(expires), 0)) {
+		return nil, fmt.Errorf("The App Check token is expired")
+	} else if !verifier.validAudience(claims["aud"]) {
+		return nil, fmt.Errorf("The App Check token has invalid audience (%v)", claims["aud"])
+	}
+
+	return &AppCheckToken{AppID: subject, Claims: claims, Expires: time.Unix(int64(expires), 0)}, nil
+}
+
+func (verifier *JWKSAppCheckVerifier) validAudience(audience interface{}) bool {
+	var values []interface{}
+	switch aud := audience.(type) {
+	case string:
+		values = []interface{}{aud}
+	case []interface{}:
+		values = aud
+	}
+	for _, value := range values {
+		for _, project := range verifier.Projects {
+			if value == "projects/"+project {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (verifier *JWKSAppCheckVerifier) publicKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+
+	if verifier.keys == nil || time.Now().After(verifier.expires) {
+		if err := verifier.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	key, ok := verifier.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("The App Check token has unknown key ID (%v)", keyID)
+	}
+	return key, nil
+}
+
+func (verifier *JWKSAppCheckVerifier) refreshKeys(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", verifier.JWKSURL, nil)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %v", err)
+	}
+	client := verifier.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Fetching the App Check keys failed (%v)", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Fetching the App Check keys failed (%v)", res.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			E   string `json:"e"`
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("The App Check keys are invalid (%v)", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	verifier.keys = keys
+	verifier.expires = time.Now().Add(6 * time.Hour)
+	return nil
+}
+
+func decodeSegment(segment string, dest interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, dest)
+}
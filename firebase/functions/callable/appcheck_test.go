@@ -0,0 +1,108 @@
+package callable_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/balesz/go/firebase/functions/callable"
+)
+
+func signAppCheckToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		encoded, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(encoded)
+	}
+	unsigned := encode(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"}) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAppCheck(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	verifier := callable.NewAppCheckVerifier("game")
+	verifier.JWKSURL = jwks.URL
+
+	var appCheck callable.AppCheck
+	handler := callable.Initializer(callable.NewHandler(func(ctx callable.Context) (interface{}, error) {
+		appCheck = ctx.AppCheck
+		return "ok", nil
+	}), callable.WithAuthMode(callable.AuthNone), callable.WithAppCheck(callable.AppCheckEnforce, verifier))
+
+	call := func(token string) int {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"data": {}}`))
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("X-Firebase-AppCheck", token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	valid := signAppCheckToken(t, key, map[string]interface{}{
+		"iss": "https://firebaseappcheck.googleapis.com/123", "sub": "1:123:web:abc",
+		"aud": []string{"projects/123", "projects/game"}, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	expired := signAppCheckToken(t, key, map[string]interface{}{
+		"iss": "https://firebaseappcheck.googleapis.com/123", "sub": "1:123:web:abc",
+		"aud": []string{"projects/game"}, "exp": time.Now().Add(-time.Hour).Unix(),
+	})
+
+	if code := call(valid); code != 200 {
+		t.Errorf("valid: %v", code)
+	} else if appCheck.Status != callable.AppCheckValid || appCheck.AppID != "1:123:web:abc" {
+		t.Errorf("appCheck: %v", appCheck)
+	} else if code := call(expired); code != 401 {
+		t.Errorf("expired: %v", code)
+	} else if code := call(""); code != 401 {
+		t.Errorf("missing: %v", code)
+	} else if code := call(valid[:len(valid)-4] + "AAAA"); code != 401 {
+		t.Errorf("tampered: %v", code)
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	var uid = "-"
+	handler := callable.Initializer(callable.NewHandler(func(ctx callable.Context) (interface{}, error) {
+		uid = ctx.Auth.UID
+		return fmt.Sprintf("appCheck: %v", ctx.AppCheck.Status), nil
+	}), callable.WithAuthMode(callable.AuthOptional), callable.WithAppCheck(callable.AppCheckMonitor, nil))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"data": null}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != 200 || uid != "" {
+		t.Errorf("status: %v, uid: %v", w.Code, uid)
+	} else if !strings.Contains(w.Body.String(), callable.AppCheckMissing) {
+		t.Errorf("body: %v", w.Body.String())
+	}
+}
@@ -0,0 +1,150 @@
+package callable
+
+import (
+	"fmt"
+	"time"
+)
+
+// Claims gets every claim of the ID token, including the custom claims
+func (it Auth) Claims() map[string]interface{} {
+	if it.Token == nil || it.Token.Claims == nil {
+		return map[string]interface{}{}
+	}
+	return it.Token.Claims
+}
+
+// Claim gets the claim of the ID token
+func (it Auth) Claim(name string) (interface{}, bool) {
+	value, ok := it.Claims()[name]
+	return value, ok
+}
+
+// StringClaim gets the claim of the ID token as string, it is empty if the claim is not a string
+func (it Auth) StringClaim(name string) string {
+	value, _ := it.Claims()[name].(string)
+	return value
+}
+
+// BoolClaim gets the claim of the ID token as bool, it is false if the claim is not a bool
+func (it Auth) BoolClaim(name string) bool {
+	value, _ := it.Claims()[name].(bool)
+	return value
+}
+
+// Email gets the email address of the user
+func (it Auth) Email() string {
+	return it.StringClaim("email")
+}
+
+// EmailVerified reports whether the email address of the user is verified
+func (it Auth) EmailVerified() bool {
+	return it.BoolClaim("email_verified")
+}
+
+// Name gets the display name of the user
+func (it Auth) Name() string {
+	return it.StringClaim("name")
+}
+
+// Picture gets the photo URL of the user
+func (it Auth) Picture() string {
+	return it.StringClaim("picture")
+}
+
+// PhoneNumber gets the phone number of the user
+func (it Auth) PhoneNumber() string {
+	return it.StringClaim("phone_number")
+}
+
+// SignInProvider gets the sign-in provider of the token (e.g. password, google.com, anonymous)
+func (it Auth) SignInProvider() string {
+	if it.Token == nil {
+		return ""
+	}
+	return it.Token.Firebase.SignInProvider
+}
+
+// TenantID gets the tenant of the user
+func (it Auth) TenantID() string {
+	if it.Token == nil {
+		return ""
+	}
+	return it.Token.Firebase.Tenant
+}
+
+// Identities gets the identities of the user by sign-in provider
+func (it Auth) Identities() map[string]interface{} {
+	if it.Token == nil || it.Token.Firebase.Identities == nil {
+		return map[string]interface{}{}
+	}
+	return it.Token.Firebase.Identities
+}
+
+// AuthTime gets the time when the user authenticated
+func (it Auth) AuthTime() time.Time {
+	if it.Token == nil || it.Token.AuthTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(it.Token.AuthTime, 0)
+}
+
+// IssuedAt gets the time when the token was issued
+func (it Auth) IssuedAt() time.Time {
+	if it.Token == nil || it.Token.IssuedAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(it.Token.IssuedAt, 0)
+}
+
+// IsAnonymous reports whether the user is signed in anonymously
+func (it Auth) IsAnonymous() bool {
+	return it.SignInProvider() == "anonymous"
+}
+
+// RequireAuth returns an unauthenticated error if there is no signed in user
+func (it Auth) RequireAuth() error {
+	if it.UID == "" {
+		return Unauthenticated("The function must be called while authenticated")
+	}
+	return nil
+}
+
+// RequireClaim returns a permission-denied error if the claim is missing, or
+// it is not one of the given values; without values the claim must not be false or null
+func (it Auth) RequireClaim(name string, values ...interface{}) error {
+	if err := it.RequireAuth(); err != nil {
+		return err
+	}
+	value, ok := it.Claim(name)
+	if !ok || value == nil || value == false {
+		return PermissionDenied(fmt.Sprintf("The %v claim is required", name))
+	} else if len(values) == 0 {
+		return nil
+	}
+	for _, val := range values {
+		if fmt.Sprint(val) == fmt.Sprint(value) {
+			return nil
+		}
+	}
+	return PermissionDenied(fmt.Sprintf("The %v claim is not permitted", name))
+}
+
+// RequireEmailVerified returns a permission-denied error if the email address is not verified
+func (it Auth) RequireEmailVerified() error {
+	if err := it.RequireAuth(); err != nil {
+		return err
+	} else if !it.EmailVerified() {
+		return PermissionDenied("The email address is not verified")
+	}
+	return nil
+}
+
+// RequireTenant returns a permission-denied error if the user is not in the tenant
+func (it Auth) RequireTenant(tenantID string) error {
+	if err := it.RequireAuth(); err != nil {
+		return err
+	} else if it.TenantID() != tenantID {
+		return PermissionDenied("The tenant is not permitted")
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"firebase.google.com/go/v4/auth"
 	"github.com/balesz/go/firebase"
 	"github.com/balesz/go/firebase/functions/logging"
 )
@@ -18,37 +19,71 @@ import (
 //ContextKey -
 const ContextKey = contextKey("context")
 
-//Initializer -
-func Initializer(next http.Handler) http.Handler {
+//Initializer - validates and authenticates the request, see the options for the authentication and App Check modes
+func Initializer(next http.Handler, opts ...Option) http.Handler {
+	options := newOptions(opts)
+	if options.revocation != nil && options.revocation.getUser == nil {
+		options.revocation.getUser = func(ctx context.Context, uid string) (*auth.UserRecord, error) {
+			client, err := options.authClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return client.GetUser(ctx, uid)
+		}
+	}
+	if options.appCheckMode != AppCheckOff && options.appCheckVerifier == nil {
+		options.appCheckVerifier = NewAppCheckVerifier(firebase.ProjectID())
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var auth Auth
 		var data interface{}
-		var httpsError httpsError
+		var size int64
 
-		var err error
-		if data, err = validateRequest(r); err != nil {
-			logging.Error(err)
-			httpsError = newError("invalid-argument", "Bad Request", http.StatusBadRequest, err)
-		} else if auth, err = authenticate(r); err != nil {
-			logging.Error(err)
-			httpsError = newError("unauthenticated", "Unauthenticated", http.StatusUnauthorized, err)
+		logger := logging.FromRequest(r)
+
+		if options.maxRequestSize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, options.maxRequestSize)
 		}
 
-		if httpsError.Code != "" {
-			if result, err := json.Marshal(httpsCallableResult{Error: httpsError}); err != nil {
-				http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+		var err error
+		if data, size, err = validateRequest(r, options.maxRequestSize); err == errRequestTooLarge {
+			logger.Error(err)
+			writeHttpsError(w, http.StatusRequestEntityTooLarge, InvalidArgument("The request is too large").WithDetails(
+				map[string]int64{"limit": options.maxRequestSize}))
+			return
+		} else if err != nil {
+			logger.Error(err)
+			writeError(w, NewHttpsError(CodeInvalidArgument, "Bad Request", err.Error()))
+			return
+		} else if auth, err = authenticateMode(r, options); err != nil {
+			logger.Error(err)
+			if httpsError, ok := err.(*HttpsError); ok {
+				writeError(w, httpsError)
 			} else {
-				w.Header().Add("Content-Type", "application/json")
-				w.Write(result)
-				w.WriteHeader(200)
+				writeError(w, Unauthenticated("Unauthenticated"))
+			}
+			return
+		}
+
+		appCheck := verifyAppCheck(r, options)
+		if appCheck.Status != "" && appCheck.Status != AppCheckValid {
+			if options.appCheckMode == AppCheckEnforce {
+				logger.Error(fmt.Errorf("App Check failed (%v)", appCheck.Status))
+				writeError(w, Unauthenticated("Unauthenticated"))
 				return
 			}
+			logger.Warning("App Check failed", "appCheck", appCheck.Status)
 		}
 
 		instanceID := r.Header.Get("Firebase-Instance-ID-Token")
 
-		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextKey, Context{
-			Auth: auth, Data: data, InstanceID: instanceID, URL: *r.URL,
+		logger = logger.With("uid", auth.UID)
+		ctx := logging.NewContext(r.Context(), logger)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, ContextKey, Context{
+			AppCheck: appCheck, Auth: auth, Data: data, InstanceID: instanceID, Logger: logger,
+			RemoteIP: logging.RemoteIP(r, options.trustedProxies), Size: size, URL: *r.URL, ctx: ctx,
 		})))
 	})
 }
@@ -61,38 +96,72 @@ func NewContext(userID string, data interface{}) Context {
 	}
 }
 
+//NewContextWithToken - creates a context with the claims of the decoded ID token
+func NewContextWithToken(token *auth.Token, data interface{}) Context {
+	return Context{
+		Auth: Auth{Token: token, UID: token.UID},
+		Data: data,
+	}
+}
+
 //NewHandler -
 func NewHandler(handler Handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context().Value(ContextKey)
-		if result, err := handler(ctx.(Context)); err != nil {
-			logging.Error(fmt.Errorf("Error: %v", err))
-			httpsError := newError("internal", "INTERNAL", http.StatusInternalServerError, err)
-			if result, err := json.Marshal(httpsCallableResult{Error: httpsError}); err != nil {
-				http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
-			} else {
-				w.Header().Add("Content-Type", "application/json")
-				w.Write(result)
-				w.WriteHeader(200)
-				return
-			}
-		} else if result != nil {
-			logging.Info(fmt.Sprintf("Result: %v", result))
-			if result, err := json.Marshal(httpsCallableResult{Result: result}); err != nil {
-				http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
-			} else {
-				w.Header().Add("Content-Type", "application/json")
-				w.Write(result)
-				w.WriteHeader(200)
-				return
-			}
+		ctx := r.Context().Value(ContextKey).(Context)
+		if result, err := handler(ctx); err != nil {
+			writeError(w, handlerError(ctx, err))
 		} else {
-			http.Error(w, "Unknown Error", http.StatusInternalServerError)
+			ctx.Logger.Info("Result", "result", result)
+			writeResult(w, result)
 		}
 	})
 }
 
-func validateRequest(r *http.Request) (data interface{}, err error) {
+// handlerError logs the error of the handler and converts it to HttpsError
+func handlerError(ctx Context, err error) *HttpsError {
+	httpsError, ok := AsHttpsError(err)
+	if ok {
+		ctx.Logger.Warning(err.Error(), "code", httpsError.Code)
+	} else {
+		ctx.Logger.Error(fmt.Errorf("Error: %v", err))
+	}
+	return httpsError
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	if encoded, err := json.Marshal(httpsCallableResult{Result: result}); err != nil {
+		logging.Error(fmt.Errorf("json.Marshal: %v", err))
+		writeError(w, Internal("INTERNAL"))
+	} else {
+		writeJSON(w, http.StatusOK, encoded)
+	}
+}
+
+func writeError(w http.ResponseWriter, httpsError *HttpsError) {
+	writeHttpsError(w, httpsError.HTTPStatus(), httpsError)
+}
+
+// writeHttpsError writes the error with the given HTTP status instead of the status of its code
+func writeHttpsError(w http.ResponseWriter, status int, httpsError *HttpsError) {
+	if encoded, err := json.Marshal(httpsCallableError{Error: httpsError}); err != nil {
+		logging.Error(fmt.Errorf("json.Marshal: %v", err))
+		encoded, _ = json.Marshal(httpsCallableError{Error: Internal("INTERNAL")})
+		writeJSON(w, http.StatusInternalServerError, encoded)
+	} else {
+		writeJSON(w, status, encoded)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, encoded []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(encoded)
+}
+
+// errRequestTooLarge is returned by validateRequest if the body exceeds the limit of http.MaxBytesReader
+var errRequestTooLarge = fmt.Errorf("Request body is too large")
+
+func validateRequest(r *http.Request, limit int64) (data interface{}, size int64, err error) {
 	if r.Method != "POST" {
 		err = fmt.Errorf("Request has invalid method (%v)", r.Method)
 		return
@@ -104,27 +173,85 @@ func validateRequest(r *http.Request) (data interface{}, err error) {
 	}
 
 	var res struct {
-		Data interface{} `json:"data"`
+		Data json.RawMessage `json:"data"`
 	}
 
 	payload, err := ioutil.ReadAll(r.Body)
+	size = int64(len(payload))
 
-	if err != nil {
+	if err != nil && limit > 0 && size >= limit {
+		err = errRequestTooLarge
+		return
+	} else if err != nil {
 		err = fmt.Errorf("Request is missing body (%v)", err)
 		return
 	} else if err = json.Unmarshal(payload, &res); err != nil {
 		err = fmt.Errorf("JSON unmarshal error (%v)", err)
 		return
-	} else if res.Data == nil {
+	} else if len(res.Data) == 0 {
 		err = fmt.Errorf("Request body is missing data (%v)", string(payload))
 		return
+	} else if err = json.Unmarshal(res.Data, &data); err != nil {
+		err = fmt.Errorf("JSON unmarshal error (%v)", err)
+		return
 	}
 
-	data = res.Data
 	return
 }
 
-func authenticate(r *http.Request) (auth Auth, err error) {
+func authenticateMode(r *http.Request, options options) (Auth, error) {
+	switch options.authMode {
+	case AuthNone:
+		return Auth{}, nil
+	case AuthOptional:
+		if r.Header.Get("Authorization") == "" {
+			return Auth{}, nil
+		}
+	}
+	// the revocation is not checked with the Auth emulator, because the Admin SDK does not support it
+	verifier, revocation := options.tokenVerifier, options.revocation
+	if verifier == nil && options.clients == nil && firebase.GetEmulators().Auth != "" {
+		emulator, err := newEmulatorTokenVerifier()
+		if err != nil {
+			return Auth{}, err
+		}
+		verifier, revocation = emulator, nil
+	} else if verifier == nil {
+		client, err := options.authClient(r.Context())
+		if err != nil {
+			return Auth{}, err
+		}
+		verifier = client
+	}
+	auth, err := authenticate(r, verifier)
+	if err != nil {
+		return Auth{}, err
+	} else if revocation != nil {
+		if err := revocation.check(r.Context(), auth.Token); err != nil {
+			return Auth{}, err
+		}
+	}
+	return auth, nil
+}
+
+func verifyAppCheck(r *http.Request, options options) AppCheck {
+	if options.appCheckMode == AppCheckOff {
+		return AppCheck{}
+	}
+	header := strings.TrimSpace(r.Header.Get("X-Firebase-AppCheck"))
+	if header == "" {
+		return AppCheck{Status: AppCheckMissing}
+	}
+	token, err := options.appCheckVerifier.VerifyToken(r.Context(), header)
+	if err != nil {
+		logging.FromRequest(r).Warning(err.Error())
+		return AppCheck{Status: AppCheckInvalid}
+	}
+	return AppCheck{AppID: token.AppID, Claims: token.Claims, Status: AppCheckValid}
+}
+
+
+func authenticate(r *http.Request, verifier TokenVerifier) (auth Auth, err error) {
 	rxToken := regexp.MustCompile("^Bearer (.*)$")
 
 	var idToken string
@@ -138,13 +265,13 @@ func authenticate(r *http.Request) (auth Auth, err error) {
 		idToken = strings.TrimSpace(rxToken.FindStringSubmatch(authorization)[1])
 	}
 
-	token, err := firebase.Auth.VerifyIDToken(context.Background(), idToken)
+	token, err := verifier.VerifyIDToken(r.Context(), idToken)
 	//userID, err := verifyIDToken(idToken)
 	if err != nil {
 		return
 	}
 
-	auth = Auth{UID: token.UID}
+	auth = Auth{Token: token, UID: token.UID}
 	return
 }
 
@@ -169,11 +296,3 @@ func verifyIDToken(token string) (string, error) {
 	}
 	return result.UserID, nil
 }
-
-func newError(code string, name string, status int, err error) httpsError {
-	return httpsError{
-		Code:          code,
-		Details:       err.Error(),
-		HTTPErrorCode: httpsErrorCode{CanonicalName: name, Status: status},
-	}
-}
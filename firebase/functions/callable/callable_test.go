@@ -1,9 +1,21 @@
 package callable_test
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
 
 	"github.com/balesz/go/firebase/functions/callable"
+	"github.com/balesz/go/firebase/functions/callable/callabletest"
 )
 
 func TestMain(t *testing.T) {
@@ -31,3 +43,393 @@ func TestMain(t *testing.T) {
 		t.Error("data.Hello.World is not true")
 	}
 }
+
+func TestProtocol(t *testing.T) {
+	serve := func(handler http.Handler, r *http.Request) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+	withContext := func(r *http.Request) *http.Request {
+		ctx := context.WithValue(r.Context(), callable.ContextKey, callable.NewContext("alice", nil))
+		return r.WithContext(ctx)
+	}
+
+	handler := callable.NewHandler(func(ctx callable.Context) (interface{}, error) { return nil, nil })
+	if code, body := serve(handler, withContext(httptest.NewRequest("POST", "/", nil))); code != 200 {
+		t.Errorf("status: %v", code)
+	} else if result, ok := body["result"]; !ok || result != nil || len(body) != 1 {
+		t.Errorf("body: %v", body)
+	}
+
+	handler = callable.NewHandler(func(ctx callable.Context) (interface{}, error) { return nil, fmt.Errorf("failed") })
+	if code, body := serve(handler, withContext(httptest.NewRequest("POST", "/", nil))); code != 500 {
+		t.Errorf("status: %v", code)
+	} else if err := body["error"].(map[string]interface{}); err["status"] != "INTERNAL" || len(body) != 1 {
+		t.Errorf("body: %v", body)
+	}
+
+	r := httptest.NewRequest("GET", "/", strings.NewReader(`{"data": null}`))
+	if code, body := serve(callable.Initializer(handler), r); code != 400 {
+		t.Errorf("status: %v", code)
+	} else if err := body["error"].(map[string]interface{}); err["status"] != "INVALID_ARGUMENT" {
+		t.Errorf("body: %v", body)
+	}
+}
+
+func TestHttpsError(t *testing.T) {
+	serve := func(err error) (int, map[string]interface{}) {
+		handler := callable.NewHandler(func(ctx callable.Context) (interface{}, error) { return nil, err })
+		r := httptest.NewRequest("POST", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), callable.ContextKey, callable.NewContext("alice", nil)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		var body struct {
+			Error map[string]interface{} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Error
+	}
+
+	notFound := callable.NotFound("Order not found").WithDetails(map[string]string{"orderId": "o1"})
+	if code, err := serve(fmt.Errorf("loadOrder: %w", notFound)); code != 404 {
+		t.Errorf("status: %v", code)
+	} else if err["status"] != "NOT_FOUND" || err["message"] != "Order not found" {
+		t.Errorf("error: %v", err)
+	} else if details := err["details"].(map[string]interface{}); details["orderId"] != "o1" {
+		t.Errorf("details: %v", details)
+	}
+
+	if code, err := serve(fmt.Errorf("sql: connection refused")); code != 500 {
+		t.Errorf("status: %v", code)
+	} else if err["status"] != "INTERNAL" || err["message"] != "INTERNAL" || err["details"] != nil {
+		t.Errorf("the internal error must be sanitized: %v", err)
+	}
+}
+
+func TestTypedHandler(t *testing.T) {
+	type Item struct {
+		SKU string `json:"sku" validate:"required,regex=^[A-Z]{3}-[0-9]+$"`
+	}
+	type Request struct {
+		Name     string   `json:"name" validate:"required,max=8"`
+		Quantity int      `json:"quantity" validate:"min=1,max=10"`
+		Currency string   `json:"currency" validate:"enum=EUR|USD"`
+		Items    []Item   `json:"items" validate:"min=1"`
+		Note     *string  `json:"note" validate:"max=3"`
+		Tags     []string `json:"tags"`
+	}
+	type Response struct {
+		Total int `json:"total"`
+	}
+
+	handler := callable.Typed(func(ctx callable.Context, req Request) (Response, error) {
+		return Response{Total: req.Quantity * 2}, nil
+	})
+
+	valid := map[string]interface{}{
+		"name": "pro", "quantity": 3, "currency": "EUR", "items": []interface{}{map[string]interface{}{"sku": "ABC-1"}},
+	}
+	if result, err := handler(callable.NewContext("alice", valid)); err != nil {
+		t.Error(err)
+	} else if result.(Response).Total != 6 {
+		t.Errorf("result: %v", result)
+	}
+
+	invalid := map[string]interface{}{
+		"name": "professional", "quantity": 0, "currency": "HUF", "items": []interface{}{map[string]interface{}{"sku": "x"}},
+	}
+	_, err := handler(callable.NewContext("alice", invalid))
+	httpsError, ok := callable.AsHttpsError(err)
+	if !ok || httpsError.Code != callable.CodeInvalidArgument {
+		t.Fatalf("error: %v", err)
+	}
+
+	fields := map[string]string{}
+	for _, field := range httpsError.Details.(map[string]interface{})["fields"].([]callable.FieldError) {
+		fields[field.Field] = field.Rule
+	}
+	want := map[string]string{"name": "max", "quantity": "min", "currency": "enum", "items[0].sku": "regex"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("%v != %v", fields, want)
+	}
+
+	negative := map[string]interface{}{
+		"name": "pro", "quantity": -5, "currency": "EUR", "items": []interface{}{map[string]interface{}{"sku": "ABC-1"}},
+	}
+	errs := callable.Validate(Request{Name: "professional", Quantity: -5, Currency: "EUR", Items: []Item{{SKU: "ABC-1"}}})
+	if _, err := handler(callable.NewContext("alice", negative)); err == nil {
+		t.Error("negative quantity must fail the min rule")
+	} else if len(errs) != 2 || errs[0].Message != "must contain at most 8 characters" || errs[1].Message != "must be at least 1" {
+		t.Errorf("errors: %v", errs)
+	}
+
+	_, err = handler(callable.NewContext("alice", map[string]interface{}{"name": "pro", "quantity": "three"}))
+	if httpsError, ok := callable.AsHttpsError(err); !ok || httpsError.Code != callable.CodeInvalidArgument {
+		t.Errorf("wrong type: %v", err)
+	} else if fields := httpsError.Details.(map[string]interface{})["fields"].([]callable.FieldError); len(fields) != 1 ||
+		fields[0].Field != "quantity" || strings.Contains(fields[0].Message, "int") {
+		t.Errorf("wrong type fields: %v", fields)
+	}
+
+	optional := callable.Typed(func(ctx callable.Context, req struct {
+		Limit int `json:"limit" validate:"max=10"`
+	}) (int, error) {
+		return req.Limit, nil
+	})
+	if result, err := optional(callable.NewContext("alice", nil)); err != nil || result != 0 {
+		t.Errorf("null data: %v %v", result, err)
+	} else if _, err := handler(callable.NewContext("alice", nil)); err == nil {
+		t.Error("null data must fail the required rule")
+	}
+}
+
+func TestAuthClaims(t *testing.T) {
+	token := &auth.Token{
+		UID:      "alice",
+		AuthTime: 1605866400,
+		Firebase: auth.FirebaseInfo{SignInProvider: "google.com", Tenant: "tenant-1"},
+		Claims: map[string]interface{}{
+			"email": "alice@example.com", "email_verified": true, "role": "admin", "tenant": "red",
+		},
+	}
+	ctx := callable.NewContextWithToken(token, nil)
+
+	if ctx.Auth.Email() != "alice@example.com" || !ctx.Auth.EmailVerified() {
+		t.Errorf("email: %v", ctx.Auth.Email())
+	} else if ctx.Auth.SignInProvider() != "google.com" || ctx.Auth.TenantID() != "tenant-1" {
+		t.Errorf("firebase: %v", ctx.Auth)
+	} else if ctx.Auth.AuthTime().Unix() != 1605866400 {
+		t.Errorf("authTime: %v", ctx.Auth.AuthTime())
+	} else if err := ctx.Auth.RequireClaim("role", "admin", "owner"); err != nil {
+		t.Error(err)
+	} else if err := ctx.Auth.RequireEmailVerified(); err != nil {
+		t.Error(err)
+	}
+
+	if err, _ := callable.AsHttpsError(ctx.Auth.RequireClaim("tenant", "blue")); err.Code != callable.CodePermissionDenied {
+		t.Errorf("error: %v", err)
+	} else if err, _ := callable.AsHttpsError(ctx.Auth.RequireClaim("beta")); err.Code != callable.CodePermissionDenied {
+		t.Errorf("error: %v", err)
+	} else if err, _ := callable.AsHttpsError(callable.Context{}.Auth.RequireEmailVerified()); err.Code != callable.CodeUnauthenticated {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) callable.Middleware {
+		return func(next callable.Handler) callable.Handler {
+			return func(ctx callable.Context) (interface{}, error) {
+				order = append(order, name)
+				return next(ctx)
+			}
+		}
+	}
+	handler := callable.Chain(func(ctx callable.Context) (interface{}, error) {
+		if ctx.Data == "panic" {
+			panic("boom")
+		}
+		return "ok", nil
+	}, callable.Recover(), trace("first"), trace("second"),
+		callable.RateLimit(callable.NewMemoryRateLimitStore(), 2, time.Minute))
+
+	code := func(err error) string {
+		if httpsError, ok := callable.AsHttpsError(err); err != nil && ok {
+			return httpsError.Code
+		}
+		return ""
+	}
+
+	if _, err := handler(callable.NewContext("alice", "ok")); err != nil {
+		t.Error(err)
+	} else if strings.Join(order, ",") != "first,second" {
+		t.Errorf("order: %v", order)
+	} else if _, err := handler(callable.NewContext("alice", "panic")); code(err) != callable.CodeInternal {
+		t.Errorf("recover: %v", err)
+	} else if _, err := handler(callable.NewContext("alice", "ok")); code(err) != callable.CodeResourceExhausted {
+		t.Errorf("rate limit: %v", err)
+	}
+
+	guarded := callable.Chain(func(ctx callable.Context) (interface{}, error) {
+		return "ok", nil
+	}, callable.RequireRole("admin"))
+	admin := &auth.Token{UID: "alice", Claims: map[string]interface{}{"role": "admin"}}
+	player := &auth.Token{UID: "bob", Claims: map[string]interface{}{"role": "player"}}
+	if _, err := guarded(callable.NewContextWithToken(admin, nil)); err != nil {
+		t.Error(err)
+	} else if _, err := guarded(callable.NewContextWithToken(player, nil)); code(err) != callable.CodePermissionDenied {
+		t.Errorf("role: %v", err)
+	} else if _, err := guarded(callable.NewContext("", nil)); code(err) != callable.CodeUnauthenticated {
+		t.Errorf("unauthenticated: %v", err)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := callable.NewRouter(callable.WithAuthMode(callable.AuthNone)).
+		Handle("echo", func(ctx callable.Context) (interface{}, error) {
+			return ctx.Data, nil
+		}).
+		Handle("hello", func(ctx callable.Context) (interface{}, error) {
+			return "hello", nil
+		}, callable.Authenticated())
+
+	call := func(path string, body string) (int, string) {
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	if endpoints := router.Endpoints(); !reflect.DeepEqual(endpoints, []string{"echo", "hello"}) {
+		t.Errorf("endpoints: %v", endpoints)
+	} else if code, body := call("/api/echo", `{"data": 1}`); code != 200 || body != `{"result":1}` {
+		t.Errorf("path: %v %v", code, body)
+	} else if code, body := call("/", `{"data": {"name": "echo", "data": "x"}}`); code != 200 || body != `{"result":"x"}` {
+		t.Errorf("name: %v %v", code, body)
+	} else if code, _ := call("/hello", `{"data": null}`); code != 401 {
+		t.Errorf("middleware: %v", code)
+	} else if code, body := call("/missing", `{"data": null}`); code != 404 || !strings.Contains(body, "NOT_FOUND") {
+		t.Errorf("not found: %v %v", code, body)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	var sendErr error
+	handler := callable.Initializer(callable.NewStreamHandler(func(ctx callable.Context, send callable.SendFunc) (interface{}, error) {
+		for i := 1; i <= 2; i++ {
+			if sendErr = send(i); sendErr != nil {
+				return nil, sendErr
+			}
+		}
+		return "done", nil
+	}), callable.WithAuthMode(callable.AuthNone))
+
+	call := func(ctx context.Context, accept string) (string, string) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"data": null}`)).WithContext(ctx)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Header().Get("Content-Type"), w.Body.String()
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expected := "data: {\"message\":1}\n\ndata: {\"message\":2}\n\ndata: {\"result\":\"done\"}\n\n"
+	if contentType, body := call(context.Background(), "text/event-stream"); contentType != "text/event-stream" || body != expected {
+		t.Errorf("stream: %v %q", contentType, body)
+	} else if _, body := call(context.Background(), "application/json"); strings.TrimSpace(body) != `{"result":"done"}` {
+		t.Errorf("fallback: %q", body)
+	} else if _, body := call(cancelled, "text/event-stream"); body != "" || sendErr != context.Canceled {
+		t.Errorf("cancelled: %q %v", body, sendErr)
+	}
+}
+
+func TestAuthEmulator(t *testing.T) {
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", "localhost:9099")
+	t.Setenv("FIREBASE_CONFIG", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("GCLOUD_PROJECT", "demo-test")
+	t.Setenv("K_SERVICE", "")
+	t.Setenv("FUNCTION_TARGET", "")
+
+	newHandler := func(opts ...callable.Option) http.Handler {
+		return callable.Initializer(callable.NewHandler(func(ctx callable.Context) (interface{}, error) {
+			return ctx.Auth.UID, nil
+		}), append([]callable.Option{callable.WithRevocationCheck(0)}, opts...)...)
+	}
+
+	call := func(handler http.Handler, alg, aud string) (int, string) {
+		encode := func(value interface{}) string {
+			encoded, _ := json.Marshal(value)
+			return base64.RawURLEncoding.EncodeToString(encoded)
+		}
+		token := encode(map[string]string{"alg": alg, "typ": "JWT"}) + "." + encode(map[string]interface{}{
+			"aud": aud, "exp": time.Now().Add(time.Hour).Unix(), "sub": "alice",
+		}) + "."
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"data": null}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	signer, err := callabletest.NewSigner("demo-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newHandler()
+	rejecting := newHandler(callable.WithTokenVerifier(signer))
+
+	if code, body := call(handler, "none", "demo-test"); code != 200 || body != `{"result":"alice"}` {
+		t.Errorf("emulator token: %v %v", code, body)
+	} else if code, _ := call(handler, "RS256", "demo-test"); code != 401 {
+		t.Errorf("signed token: %v", code)
+	} else if code, _ := call(handler, "none", "other-project"); code != 401 {
+		t.Errorf("other project: %v", code)
+	} else if code, _ := call(rejecting, "none", "demo-test"); code != 401 {
+		t.Errorf("injected verifier: %v", code)
+	}
+
+	t.Setenv("GCLOUD_PROJECT", "")
+	if code, _ := call(handler, "none", ""); code != 401 {
+		t.Errorf("without project: %v", code)
+	}
+
+	t.Setenv("GCLOUD_PROJECT", "demo-test")
+	t.Setenv("K_SERVICE", "function")
+	if code, _ := call(handler, "none", "demo-test"); code != 401 {
+		t.Errorf("deployed runtime: %v", code)
+	}
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	handler := callable.Initializer(callable.NewHandler(func(ctx callable.Context) (interface{}, error) {
+		return ctx.Size, nil
+	}), callable.WithAuthMode(callable.AuthNone), callable.WithMaxRequestSize(32))
+
+	call := func(body string) (int, string) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	if code, body := call(`{"data": "ok"}`); code != 200 || body != `{"result":14}` {
+		t.Errorf("small: %v %v", code, body)
+	} else if code, body := call(`{"data": "` + strings.Repeat("x", 64) + `"}`); code != 413 || !strings.Contains(body, "INVALID_ARGUMENT") {
+		t.Errorf("large: %v %v", code, body)
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	handler := callable.Initializer(callable.NewHandler(callable.Chain(func(ctx callable.Context) (interface{}, error) {
+		return ctx.RemoteIP, nil
+	}, callable.RateLimit(callable.NewMemoryRateLimitStore(), 1, time.Minute))), callable.WithAuthMode(callable.AuthNone))
+
+	call := func(forwardedFor string) int {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"data": null}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := call("203.0.113.1"); code != 200 {
+		t.Errorf("first: %v", code)
+	} else if code := call("198.51.100.1, 203.0.113.1"); code != 429 {
+		t.Errorf("spoofed hop: %v", code)
+	} else if code := call("198.51.100.2, 203.0.113.1"); code != 429 {
+		t.Errorf("other spoofed hop: %v", code)
+	} else if code := call("203.0.113.2"); code != 200 {
+		t.Errorf("other client: %v", code)
+	}
+}
@@ -0,0 +1,36 @@
+package callabletest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// AssertResult fails the test if the response has an error or its result is not equal
+// to the expected value, the values are compared by their JSON encoding
+func AssertResult(t testing.TB, res *Response, expected interface{}) {
+	t.Helper()
+	if res.Error != nil {
+		t.Errorf("unexpected error: %v", res.Error)
+		return
+	}
+	encoded, err := json.Marshal(expected)
+	if err != nil {
+		t.Errorf("json.Marshal: %v", err)
+		return
+	}
+	var want, got interface{}
+	json.Unmarshal(encoded, &want)
+	json.Unmarshal(res.Result, &got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("result: %s, expected: %s", res.Result, encoded)
+	}
+}
+
+// AssertError fails the test if the error code of the response is not the expected one
+func AssertError(t testing.TB, res *Response, code string) {
+	t.Helper()
+	if res.Code() != code {
+		t.Errorf("code: %v, expected: %v (%s)", res.Code(), code, res.Body)
+	}
+}
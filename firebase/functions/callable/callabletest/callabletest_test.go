@@ -0,0 +1,55 @@
+package callabletest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/balesz/go/firebase/functions/callable"
+	"github.com/balesz/go/firebase/functions/callable/callabletest"
+)
+
+func TestClient(t *testing.T) {
+	signer, err := callabletest.NewSigner("game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := callabletest.NewSigner("game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := callable.Initializer(callable.NewHandler(func(ctx callable.Context) (interface{}, error) {
+		if err := ctx.Auth.RequireClaim("role", "admin"); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"uid": ctx.Auth.UID, "data": ctx.Data}, nil
+	}), callable.WithTokenVerifier(signer))
+	client := callabletest.NewClient(handler)
+
+	admin := client.WithIDToken(signer.MustToken("alice", map[string]interface{}{"role": "admin"}))
+	if res, err := admin.Call(1); err != nil {
+		t.Fatal(err)
+	} else {
+		callabletest.AssertResult(t, res, map[string]interface{}{"uid": "alice", "data": 1})
+	}
+
+	player := client.WithIDToken(signer.MustToken("bob", map[string]interface{}{"role": "player"}))
+	if res, err := player.Call(nil); err != nil {
+		t.Fatal(err)
+	} else {
+		callabletest.AssertError(t, res, callable.CodePermissionDenied)
+	}
+
+	forged := client.WithIDToken(other.MustToken("alice", map[string]interface{}{"role": "admin"}))
+	if res, err := forged.Call(nil); err != nil {
+		t.Fatal(err)
+	} else {
+		callabletest.AssertError(t, res, callable.CodeUnauthenticated)
+	}
+
+	if token, err := signer.VerifyIDToken(context.Background(), signer.MustToken("carol", map[string]interface{}{"email": "c@x"})); err != nil {
+		t.Error(err)
+	} else if token.UID != "carol" || token.Claims["email"] != "c@x" || token.Claims["iat"] != nil {
+		t.Errorf("token: %v", token)
+	}
+}
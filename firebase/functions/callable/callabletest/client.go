@@ -0,0 +1,117 @@
+package callabletest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/balesz/go/firebase/functions/callable"
+)
+
+// codes of the callable protocol by canonical name
+var codes = map[string]string{}
+
+func init() {
+	for _, code := range []string{
+		callable.CodeOK, callable.CodeCancelled, callable.CodeUnknown, callable.CodeInvalidArgument,
+		callable.CodeDeadlineExceeded, callable.CodeNotFound, callable.CodeAlreadyExists,
+		callable.CodePermissionDenied, callable.CodeResourceExhausted, callable.CodeFailedPrecondition,
+		callable.CodeAborted, callable.CodeOutOfRange, callable.CodeUnimplemented, callable.CodeInternal,
+		callable.CodeUnavailable, callable.CodeDataLoss, callable.CodeUnauthenticated,
+	} {
+		codes[callable.NewHttpsError(code, "", nil).Status] = code
+	}
+}
+
+// Client calls the handler in-process with the wire format of the callable protocol
+type Client struct {
+	AppCheckToken string
+	Handler       http.Handler
+	Headers       http.Header
+	IDToken       string
+	Path          string
+}
+
+// NewClient creates a client of the handler, which is usually wrapped by callable.Initializer
+func NewClient(handler http.Handler) *Client {
+	return &Client{Handler: handler, Headers: http.Header{}, Path: "/"}
+}
+
+// WithIDToken returns a copy of the client sending the ID token
+func (client *Client) WithIDToken(idToken string) *Client {
+	copied := *client
+	copied.IDToken = idToken
+	return &copied
+}
+
+// WithPath returns a copy of the client calling the path, e.g. the name of the callable of a callable.Router
+func (client *Client) WithPath(path string) *Client {
+	copied := *client
+	copied.Path = path
+	return &copied
+}
+
+// Call sends the data to the handler and decodes the response
+func (client *Client) Call(data interface{}) (*Response, error) {
+	body, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	r := httptest.NewRequest("POST", client.Path, bytes.NewReader(body))
+	for key, values := range client.Headers {
+		r.Header[key] = values
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if client.IDToken != "" {
+		r.Header.Set("Authorization", "Bearer "+client.IDToken)
+	}
+	if client.AppCheckToken != "" {
+		r.Header.Set("X-Firebase-AppCheck", client.AppCheckToken)
+	}
+
+	w := httptest.NewRecorder()
+	client.Handler.ServeHTTP(w, r)
+
+	res := &Response{Body: w.Body.Bytes(), StatusCode: w.Code}
+	var decoded struct {
+		Error  *callable.HttpsError `json:"error"`
+		Result json.RawMessage      `json:"result"`
+	}
+	if err := json.Unmarshal(res.Body, &decoded); err != nil {
+		return res, fmt.Errorf("json.Unmarshal: %v (%s)", err, res.Body)
+	}
+	if decoded.Error != nil {
+		decoded.Error.Code = codes[decoded.Error.Status]
+	}
+	res.Error, res.Result = decoded.Error, decoded.Result
+	return res, nil
+}
+
+// Response is the decoded response of the callable
+type Response struct {
+	Body       []byte
+	Error      *callable.HttpsError
+	Result     json.RawMessage
+	StatusCode int
+}
+
+// Code gets the error code of the response, or ok if there is no error
+func (res *Response) Code() string {
+	if res.Error != nil {
+		return res.Error.Code
+	}
+	return callable.CodeOK
+}
+
+// ResultTo decodes the result into the destination
+func (res *Response) ResultTo(dest interface{}) error {
+	if res.Error != nil {
+		return res.Error
+	} else if err := json.Unmarshal(res.Result, dest); err != nil {
+		return fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package callable
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/balesz/go/firebase"
+)
+
+// emulatorTokenVerifier decodes the unsigned ID tokens of the Auth emulator, it is
+// the default verifier if FIREBASE_AUTH_EMULATOR_HOST is set and neither a verifier
+// nor clients are given to the Initializer
+type emulatorTokenVerifier struct {
+	projectID string
+}
+
+// newEmulatorTokenVerifier creates the verifier of the Auth emulator, it refuses
+// the deployed runtimes and requires the project the tokens are issued for
+func newEmulatorTokenVerifier() (emulatorTokenVerifier, error) {
+	for _, key := range []string{"K_SERVICE", "FUNCTION_TARGET"} {
+		if os.Getenv(key) != "" {
+			return emulatorTokenVerifier{}, fmt.Errorf("The Auth emulator is configured in a deployed runtime (%v is set)", key)
+		}
+	}
+	projectID := firebase.ProjectID()
+	if projectID == "" {
+		return emulatorTokenVerifier{}, fmt.Errorf("The project of the Auth emulator not found (set FIREBASE_CONFIG or GCLOUD_PROJECT)")
+	}
+	return emulatorTokenVerifier{projectID: projectID}, nil
+}
+
+func (verifier emulatorTokenVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("The ID token is malformed")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	var token auth.Token
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("The ID token header is invalid (%v)", err)
+	} else if header.Algorithm != "none" {
+		return nil, fmt.Errorf("The ID token is not issued by the Auth emulator (%v)", header.Algorithm)
+	} else if err := decodeSegment(parts[1], &token); err != nil {
+		return nil, fmt.Errorf("The ID token payload is invalid (%v)", err)
+	} else if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("The ID token payload is invalid (%v)", err)
+	} else if token.Subject == "" {
+		return nil, fmt.Errorf("The ID token has no subject")
+	} else if time.Now().After(time.Unix(token.Expires, 0)) {
+		return nil, fmt.Errorf("The ID token is expired")
+	} else if verifier.projectID == "" || token.Audience != verifier.projectID {
+		return nil, fmt.Errorf("The ID token has invalid audience (%v)", token.Audience)
+	}
+
+	for _, claim := range []string{"aud", "auth_time", "exp", "firebase", "iat", "iss", "sub", "uid"} {
+		delete(claims, claim)
+	}
+	token.UID = token.Subject
+	token.Claims = claims
+	return &token, nil
+}
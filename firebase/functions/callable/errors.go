@@ -0,0 +1,184 @@
+package callable
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes of the callable protocol
+const (
+	CodeOK                 = "ok"
+	CodeCancelled          = "cancelled"
+	CodeUnknown            = "unknown"
+	CodeInvalidArgument    = "invalid-argument"
+	CodeDeadlineExceeded   = "deadline-exceeded"
+	CodeNotFound           = "not-found"
+	CodeAlreadyExists      = "already-exists"
+	CodePermissionDenied   = "permission-denied"
+	CodeResourceExhausted  = "resource-exhausted"
+	CodeFailedPrecondition = "failed-precondition"
+	CodeAborted            = "aborted"
+	CodeOutOfRange         = "out-of-range"
+	CodeUnimplemented      = "unimplemented"
+	CodeInternal           = "internal"
+	CodeUnavailable        = "unavailable"
+	CodeDataLoss           = "data-loss"
+	CodeUnauthenticated    = "unauthenticated"
+)
+
+type httpsErrorCode struct {
+	CanonicalName string
+	Status        int
+}
+
+// httpsErrorCodes maps the error codes of the callable protocol to the
+// canonical names and the HTTP status codes
+var httpsErrorCodes = map[string]httpsErrorCode{
+	CodeOK:                 {CanonicalName: "OK", Status: 200},
+	CodeCancelled:          {CanonicalName: "CANCELLED", Status: 499},
+	CodeUnknown:            {CanonicalName: "UNKNOWN", Status: 500},
+	CodeInvalidArgument:    {CanonicalName: "INVALID_ARGUMENT", Status: 400},
+	CodeDeadlineExceeded:   {CanonicalName: "DEADLINE_EXCEEDED", Status: 504},
+	CodeNotFound:           {CanonicalName: "NOT_FOUND", Status: 404},
+	CodeAlreadyExists:      {CanonicalName: "ALREADY_EXISTS", Status: 409},
+	CodePermissionDenied:   {CanonicalName: "PERMISSION_DENIED", Status: 403},
+	CodeResourceExhausted:  {CanonicalName: "RESOURCE_EXHAUSTED", Status: 429},
+	CodeFailedPrecondition: {CanonicalName: "FAILED_PRECONDITION", Status: 400},
+	CodeAborted:            {CanonicalName: "ABORTED", Status: 409},
+	CodeOutOfRange:         {CanonicalName: "OUT_OF_RANGE", Status: 400},
+	CodeUnimplemented:      {CanonicalName: "UNIMPLEMENTED", Status: 501},
+	CodeInternal:           {CanonicalName: "INTERNAL", Status: 500},
+	CodeUnavailable:        {CanonicalName: "UNAVAILABLE", Status: 503},
+	CodeDataLoss:           {CanonicalName: "DATA_LOSS", Status: 500},
+	CodeUnauthenticated:    {CanonicalName: "UNAUTHENTICATED", Status: 401},
+}
+
+// HttpsError is an error which is sent to the client with its code, message and details
+type HttpsError struct {
+	Code    string      `json:"-"`
+	Details interface{} `json:"details,omitempty"`
+	Message string      `json:"message"`
+	Status  string      `json:"status"`
+}
+
+// NewHttpsError creates a new HttpsError, unknown codes are replaced with internal
+func NewHttpsError(code string, message string, details interface{}) *HttpsError {
+	if _, ok := httpsErrorCodes[code]; !ok {
+		code = CodeInternal
+	}
+	return &HttpsError{
+		Code:    code,
+		Details: details,
+		Message: message,
+		Status:  httpsErrorCodes[code].CanonicalName,
+	}
+}
+
+func (err *HttpsError) Error() string {
+	if err.Details != nil {
+		return fmt.Sprintf("%v: %v (%v)", err.Code, err.Message, err.Details)
+	}
+	return fmt.Sprintf("%v: %v", err.Code, err.Message)
+}
+
+// HTTPStatus gets the HTTP status code of the error
+func (err *HttpsError) HTTPStatus() int {
+	if code, ok := httpsErrorCodes[err.Code]; ok {
+		return code.Status
+	}
+	return httpsErrorCodes[CodeInternal].Status
+}
+
+// WithDetails returns a copy of the error with the given details, it must be encodable to JSON
+func (err *HttpsError) WithDetails(details interface{}) *HttpsError {
+	return &HttpsError{Code: err.Code, Details: details, Message: err.Message, Status: err.Status}
+}
+
+// AsHttpsError converts the error to HttpsError, the unknown errors become a sanitized internal error
+func AsHttpsError(err error) (*HttpsError, bool) {
+	var httpsError *HttpsError
+	if errors.As(err, &httpsError) {
+		return httpsError, true
+	}
+	return NewHttpsError(CodeInternal, "INTERNAL", nil), false
+}
+
+// Cancelled creates a cancelled error
+func Cancelled(message string) *HttpsError {
+	return NewHttpsError(CodeCancelled, message, nil)
+}
+
+// Unknown creates an unknown error
+func Unknown(message string) *HttpsError {
+	return NewHttpsError(CodeUnknown, message, nil)
+}
+
+// InvalidArgument creates an invalid-argument error
+func InvalidArgument(message string) *HttpsError {
+	return NewHttpsError(CodeInvalidArgument, message, nil)
+}
+
+// DeadlineExceeded creates a deadline-exceeded error
+func DeadlineExceeded(message string) *HttpsError {
+	return NewHttpsError(CodeDeadlineExceeded, message, nil)
+}
+
+// NotFound creates a not-found error
+func NotFound(message string) *HttpsError {
+	return NewHttpsError(CodeNotFound, message, nil)
+}
+
+// AlreadyExists creates an already-exists error
+func AlreadyExists(message string) *HttpsError {
+	return NewHttpsError(CodeAlreadyExists, message, nil)
+}
+
+// PermissionDenied creates a permission-denied error
+func PermissionDenied(message string) *HttpsError {
+	return NewHttpsError(CodePermissionDenied, message, nil)
+}
+
+// ResourceExhausted creates a resource-exhausted error
+func ResourceExhausted(message string) *HttpsError {
+	return NewHttpsError(CodeResourceExhausted, message, nil)
+}
+
+// FailedPrecondition creates a failed-precondition error
+func FailedPrecondition(message string) *HttpsError {
+	return NewHttpsError(CodeFailedPrecondition, message, nil)
+}
+
+// Aborted creates an aborted error
+func Aborted(message string) *HttpsError {
+	return NewHttpsError(CodeAborted, message, nil)
+}
+
+// OutOfRange creates an out-of-range error
+func OutOfRange(message string) *HttpsError {
+	return NewHttpsError(CodeOutOfRange, message, nil)
+}
+
+// Unimplemented creates an unimplemented error
+func Unimplemented(message string) *HttpsError {
+	return NewHttpsError(CodeUnimplemented, message, nil)
+}
+
+// Internal creates an internal error
+func Internal(message string) *HttpsError {
+	return NewHttpsError(CodeInternal, message, nil)
+}
+
+// Unavailable creates an unavailable error
+func Unavailable(message string) *HttpsError {
+	return NewHttpsError(CodeUnavailable, message, nil)
+}
+
+// DataLoss creates a data-loss error
+func DataLoss(message string) *HttpsError {
+	return NewHttpsError(CodeDataLoss, message, nil)
+}
+
+// Unauthenticated creates an unauthenticated error
+func Unauthenticated(message string) *HttpsError {
+	return NewHttpsError(CodeUnauthenticated, message, nil)
+}
@@ -0,0 +1,82 @@
+package callable
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a handler with a cross-cutting policy
+type Middleware func(next Handler) Handler
+
+// Chain wraps the handler with the middlewares, the first middleware is the outermost
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Recover converts the panics of the handler to internal errors
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx Context) (result interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					ctx.Logger.Critical(fmt.Errorf("panic: %v", r), "stack", string(debug.Stack()))
+					result, err = nil, Internal("INTERNAL")
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// Timing logs the duration of the handler
+func Timing() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx Context) (interface{}, error) {
+			start := time.Now()
+			result, err := next(ctx)
+			duration := time.Since(start)
+			ctx.Logger.Info(fmt.Sprintf("Finished in %v", duration),
+				"duration", duration.Seconds(), "failed", err != nil)
+			return result, err
+		}
+	}
+}
+
+// Guard calls the check before the handler and returns its error, if any
+func Guard(check func(ctx Context) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx Context) (interface{}, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Authenticated rejects the requests without a signed in user
+func Authenticated() Middleware {
+	return Guard(func(ctx Context) error {
+		return ctx.Auth.RequireAuth()
+	})
+}
+
+// RequireClaim rejects the requests without the claim, see Auth.RequireClaim
+func RequireClaim(name string, values ...interface{}) Middleware {
+	return Guard(func(ctx Context) error {
+		return ctx.Auth.RequireClaim(name, values...)
+	})
+}
+
+// RequireRole rejects the requests whose role claim is not one of the roles
+func RequireRole(roles ...string) Middleware {
+	values := make([]interface{}, len(roles))
+	for i, role := range roles {
+		values[i] = role
+	}
+	return RequireClaim("role", values...)
+}
@@ -0,0 +1,120 @@
+package callable
+
+import (
+	"context"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/balesz/go/firebase"
+	"github.com/balesz/go/firebase/functions/logging"
+)
+
+// Authentication modes of the Initializer
+const (
+	// AuthRequired rejects the requests without a valid ID token
+	AuthRequired = "required"
+	// AuthOptional accepts the requests without ID token, but rejects the invalid ones
+	AuthOptional = "optional"
+	// AuthNone ignores the Authorization header
+	AuthNone = "none"
+)
+
+// App Check modes of the Initializer
+const (
+	// AppCheckOff ignores the X-Firebase-AppCheck header
+	AppCheckOff = "off"
+	// AppCheckMonitor verifies the token and logs the failures without rejecting the request
+	AppCheckMonitor = "monitor"
+	// AppCheckEnforce rejects the requests without a valid App Check token
+	AppCheckEnforce = "enforce"
+)
+
+// TokenVerifier verifies the ID token of the Authorization header, it is implemented by auth.Client
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+}
+
+// Option configures the Initializer
+type Option func(*options)
+
+type options struct {
+	appCheckMode     string
+	appCheckVerifier AppCheckVerifier
+	authMode         string
+	clients          *firebase.Clients
+	maxRequestSize   int64
+	revocation       *revocationCache
+	tokenVerifier    TokenVerifier
+	trustedProxies   int
+}
+
+func newOptions(opts []Option) options {
+	result := options{appCheckMode: AppCheckOff, authMode: AuthRequired, trustedProxies: logging.DefaultTrustedProxies}
+	for _, opt := range opts {
+		opt(&result)
+	}
+	return result
+}
+
+// WithAuthMode sets the authentication mode, the default is AuthRequired
+func WithAuthMode(mode string) Option {
+	return func(opts *options) {
+		opts.authMode = mode
+	}
+}
+
+// WithAppCheck sets the App Check mode and the verifier of the tokens, the
+// default verifier accepts the tokens of the project of the environment
+func WithAppCheck(mode string, verifier AppCheckVerifier) Option {
+	return func(opts *options) {
+		opts.appCheckMode = mode
+		opts.appCheckVerifier = verifier
+	}
+}
+
+// WithRevocationCheck rejects the revoked ID tokens and the tokens of the disabled
+// users, the status of the users is cached for the ttl, DefaultRevocationTTL if zero
+func WithRevocationCheck(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.revocation = newRevocationCache(ttl)
+	}
+}
+
+// WithTokenVerifier sets the verifier of the ID tokens, the default is the Auth client of
+// the clients. Without verifier and clients the unsigned tokens of the Auth emulator are
+// accepted if FIREBASE_AUTH_EMULATOR_HOST is set, except in a deployed runtime.
+func WithTokenVerifier(verifier TokenVerifier) Option {
+	return func(opts *options) {
+		opts.tokenVerifier = verifier
+	}
+}
+
+// WithClients sets the Firebase clients used for the authentication, the default is firebase.DefaultClients
+func WithClients(clients *firebase.Clients) Option {
+	return func(opts *options) {
+		opts.clients = clients
+	}
+}
+
+// authClient gets the Auth client of the clients, it is resolved on every call
+// because the default clients may be initialized after the Initializer
+func (opts options) authClient(ctx context.Context) (*auth.Client, error) {
+	return opts.clients.Resolve().GetAuth(ctx)
+}
+
+// WithMaxRequestSize limits the size of the request body in bytes, the larger
+// requests are rejected with 413 status and invalid-argument error before the body is read
+func WithMaxRequestSize(limit int64) Option {
+	return func(opts *options) {
+		opts.maxRequestSize = limit
+	}
+}
+
+// WithTrustedProxies sets the number of the proxies which append the address of their client
+// to X-Forwarded-For, the default is logging.DefaultTrustedProxies. The IP address of the
+// client is the hop appended by the first trusted proxy, see Context.RemoteIP.
+func WithTrustedProxies(count int) Option {
+	return func(opts *options) {
+		opts.trustedProxies = count
+	}
+}
@@ -0,0 +1,79 @@
+package callable
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitStore counts the requests of the keys in fixed windows
+type RateLimitStore interface {
+	// Allow records a request of the key and reports whether it is within the limit of the window
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+// RateLimit rejects the requests of the user above the limit in the window with
+// resource-exhausted error. The requests without signed in user are limited by
+// the IP address of the client appended by the trusted proxies, see Context.RemoteIP.
+// The requests are allowed if the store fails.
+func RateLimit(store RateLimitStore, limit int, window time.Duration) Middleware {
+	return RateLimitBy(store, limit, window, RateLimitKey)
+}
+
+// RateLimitBy is like RateLimit, but the requests are grouped by the key function
+func RateLimitBy(store RateLimitStore, limit int, window time.Duration, key func(ctx Context) string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx Context) (interface{}, error) {
+			if allowed, err := store.Allow(ctx.Context(), key(ctx), limit, window); err != nil {
+				ctx.Logger.Error(err)
+			} else if !allowed {
+				return nil, ResourceExhausted("Too many requests")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// RateLimitKey is the default key of RateLimit, the UID of the signed in user or the IP address of the client
+func RateLimitKey(ctx Context) string {
+	if ctx.Auth.UID != "" {
+		return "uid:" + ctx.Auth.UID
+	}
+	return "ip:" + ctx.RemoteIP
+}
+
+type rateLimitWindow struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryRateLimitStore counts the requests in memory, the counts are per function instance
+type MemoryRateLimitStore struct {
+	mutex   sync.Mutex
+	windows map[string]rateLimitWindow
+}
+
+// NewMemoryRateLimitStore creates a new MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{windows: map[string]rateLimitWindow{}}
+}
+
+// Allow records a request of the key and reports whether it is within the limit of the window
+func (store *MemoryRateLimitStore) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	current, ok := store.windows[key]
+	if !ok || now.After(current.expires) {
+		for key, val := range store.windows {
+			if now.After(val.expires) {
+				delete(store.windows, key)
+			}
+		}
+		current = rateLimitWindow{expires: now.Add(window)}
+	}
+	current.count++
+	store.windows[key] = current
+	return current.count <= limit, nil
+}
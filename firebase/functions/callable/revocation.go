@@ -0,0 +1,86 @@
+package callable
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// DefaultRevocationTTL is the default time while the revocation status of a user is cached
+const DefaultRevocationTTL = time.Minute
+
+// Reasons of the rejected ID tokens in the details of the unauthenticated errors
+var (
+	ErrTokenRevoked = Unauthenticated("The ID token has been revoked").WithDetails(map[string]string{"reason": "token-revoked"})
+	ErrUserDisabled = Unauthenticated("The user account has been disabled").WithDetails(map[string]string{"reason": "user-disabled"})
+)
+
+// revocationStatus is the cached state of the user which invalidates its tokens
+type revocationStatus struct {
+	disabled   bool
+	expires    time.Time
+	validAfter int64
+}
+
+// revocationCache checks whether the tokens are revoked or the users are disabled,
+// it caches the status per UID to limit the calls of the Auth API. The getUser
+// is set by the Initializer.
+type revocationCache struct {
+	getUser  func(ctx context.Context, uid string) (*auth.UserRecord, error)
+	mutex    sync.Mutex
+	statuses map[string]revocationStatus
+	ttl      time.Duration
+}
+
+func newRevocationCache(ttl time.Duration) *revocationCache {
+	if ttl <= 0 {
+		ttl = DefaultRevocationTTL
+	}
+	return &revocationCache{statuses: map[string]revocationStatus{}, ttl: ttl}
+}
+
+// check returns ErrUserDisabled or ErrTokenRevoked if the token must be
+// rejected, it performs the same check as auth.VerifyIDTokenAndCheckRevoked
+func (cache *revocationCache) check(ctx context.Context, token *auth.Token) error {
+	status, err := cache.status(ctx, token.UID)
+	if err != nil {
+		return err
+	} else if status.disabled {
+		return ErrUserDisabled
+	} else if token.IssuedAt*1000 < status.validAfter {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (cache *revocationCache) status(ctx context.Context, uid string) (revocationStatus, error) {
+	cache.mutex.Lock()
+	status, ok := cache.statuses[uid]
+	cache.mutex.Unlock()
+	if ok && time.Now().Before(status.expires) {
+		return status, nil
+	}
+
+	user, err := cache.getUser(ctx, uid)
+	if err != nil {
+		return revocationStatus{}, fmt.Errorf("Auth.GetUser: %v", err)
+	}
+	status = revocationStatus{
+		disabled:   user.Disabled,
+		expires:    time.Now().Add(cache.ttl),
+		validAfter: user.TokensValidAfterMillis,
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key, val := range cache.statuses {
+		if time.Now().After(val.expires) {
+			delete(cache.statuses, key)
+		}
+	}
+	cache.statuses[uid] = status
+	return status, nil
+}
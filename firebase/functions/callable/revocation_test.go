@@ -0,0 +1,40 @@
+package callable
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+func TestRevocationCache(t *testing.T) {
+	calls := 0
+	users := map[string]*auth.UserRecord{
+		"active":   {UserInfo: &auth.UserInfo{UID: "active"}, TokensValidAfterMillis: 1000 * 1000},
+		"disabled": {UserInfo: &auth.UserInfo{UID: "disabled"}, Disabled: true},
+	}
+	cache := newRevocationCache(time.Hour)
+	cache.getUser = func(ctx context.Context, uid string) (*auth.UserRecord, error) {
+		calls++
+		return users[uid], nil
+	}
+
+	ctx := context.Background()
+	if err := cache.check(ctx, &auth.Token{UID: "active", IssuedAt: 2000}); err != nil {
+		t.Error(err)
+	} else if err := cache.check(ctx, &auth.Token{UID: "active", IssuedAt: 500}); err != ErrTokenRevoked {
+		t.Errorf("revoked: %v", err)
+	} else if err := cache.check(ctx, &auth.Token{UID: "disabled", IssuedAt: 2000}); err != ErrUserDisabled {
+		t.Errorf("disabled: %v", err)
+	} else if calls != 2 {
+		t.Errorf("calls: %v", calls)
+	}
+
+	cache.statuses["active"] = revocationStatus{expires: time.Now().Add(-time.Second)}
+	if err := cache.check(ctx, &auth.Token{UID: "active", IssuedAt: 2000}); err != nil {
+		t.Error(err)
+	} else if calls != 3 {
+		t.Errorf("expired calls: %v", calls)
+	}
+}
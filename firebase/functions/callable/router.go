@@ -0,0 +1,90 @@
+package callable
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Router serves many named callables from one HTTP handler. The name of the
+// callable is the last segment of the URL path (e.g. /api/addScore), or if the
+// path has no name, the name field of the data, whose data field is passed to
+// the callable (e.g. {"data": {"name": "addScore", "data": {"score": 10}}}).
+type Router struct {
+	handler     http.Handler
+	middlewares []Middleware
+	routes      map[string]routerRoute
+}
+
+type routerRoute struct {
+	handler     Handler
+	middlewares []Middleware
+}
+
+// NewRouter creates a new Router, the options are passed to the Initializer
+func NewRouter(opts ...Option) *Router {
+	router := &Router{routes: map[string]routerRoute{}}
+	router.handler = Initializer(http.HandlerFunc(router.dispatch), opts...)
+	return router
+}
+
+// Use adds middlewares to every callable of the router, they run before the middlewares of the callable
+func (router *Router) Use(middlewares ...Middleware) *Router {
+	router.middlewares = append(router.middlewares, middlewares...)
+	return router
+}
+
+// Handle registers the callable with the name, it panics if the name is empty or already registered
+func (router *Router) Handle(name string, handler Handler, middlewares ...Middleware) *Router {
+	if name == "" || strings.Contains(name, "/") {
+		panic(fmt.Sprintf("callable: invalid name (%v)", name))
+	} else if _, ok := router.routes[name]; ok {
+		panic(fmt.Sprintf("callable: multiple registrations for %v", name))
+	}
+	router.routes[name] = routerRoute{handler: handler, middlewares: middlewares}
+	return router
+}
+
+// Endpoints gets the sorted names of the registered callables
+func (router *Router) Endpoints() []string {
+	names := make([]string, 0, len(router.routes))
+	for name := range router.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP validates and authenticates the request and invokes the callable
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router.handler.ServeHTTP(w, r)
+}
+
+func (router *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context().Value(ContextKey).(Context)
+
+	name := path.Base(strings.TrimSuffix(r.URL.Path, "/"))
+	if name == "." || name == "/" {
+		name = ""
+		if data, ok := ctx.Data.(map[string]interface{}); ok {
+			name, _ = data["name"].(string)
+			ctx.Data = data["data"]
+		}
+	}
+	ctx.Logger = ctx.Logger.With("function", name)
+
+	var handler Handler
+	if route, ok := router.routes[name]; !ok {
+		handler = func(ctx Context) (interface{}, error) {
+			return nil, NotFound(fmt.Sprintf("The function %v is not found", name))
+		}
+	} else {
+		middlewares := append(append([]Middleware{}, router.middlewares...), route.middlewares...)
+		handler = Chain(route.handler, middlewares...)
+	}
+
+	NewHandler(handler).ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextKey, ctx)))
+}
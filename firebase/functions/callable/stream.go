@@ -0,0 +1,78 @@
+package callable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// StreamHandler is a handler which sends partial results before the final result
+type StreamHandler func(ctx Context, send SendFunc) (interface{}, error)
+
+// SendFunc sends a partial result to the client, it returns the error of the
+// context if the client is disconnected. It does nothing for non-streaming clients.
+type SendFunc func(chunk interface{}) error
+
+type httpsCallableMessage struct {
+	Message interface{} `json:"message"`
+}
+
+// NewStreamHandler creates an HTTP handler from the streaming handler. The clients
+// accepting text/event-stream receive the partial results and the final result
+// as server-sent events, the other clients receive only the final result.
+func NewStreamHandler(handler StreamHandler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context().Value(ContextKey).(Context)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			NewHandler(func(ctx Context) (interface{}, error) {
+				return handler(ctx, func(chunk interface{}) error { return ctx.Context().Err() })
+			}).ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		var mutex sync.Mutex
+		send := func(event interface{}) error {
+			if err := ctx.Context().Err(); err != nil {
+				return err
+			}
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("json.Marshal: %v", err)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", encoded); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		result, err := handler(ctx, func(chunk interface{}) error {
+			return send(httpsCallableMessage{Message: chunk})
+		})
+		if ctx.Context().Err() != nil {
+			ctx.Logger.Warning("The client is disconnected")
+			return
+		} else if err != nil {
+			err = send(httpsCallableError{Error: handlerError(ctx, err)})
+		} else {
+			ctx.Logger.Info("Result", "result", result)
+			if err = send(httpsCallableResult{Result: result}); err != nil {
+				err = send(httpsCallableError{Error: Internal("INTERNAL")})
+			}
+		}
+		if err != nil {
+			ctx.Logger.Error(fmt.Errorf("Sending the result failed (%v)", err))
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package callable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// TypedHandler is a handler with decoded request and typed response
+type TypedHandler[Req any, Resp any] func(ctx Context, req Req) (Resp, error)
+
+// Typed converts the typed handler to Handler. The data of the context is
+// decoded into Req and validated by its validate tags, the failures are
+// returned as an invalid-argument error listing every failed field. The null
+// data is decoded to the zero Req, so the validate tags decide whether it is valid.
+func Typed[Req any, Resp any](handler TypedHandler[Req, Resp]) Handler {
+	return func(ctx Context) (interface{}, error) {
+		var req Req
+		if err := decodeData(ctx.Data, &req); err != nil {
+			return nil, err
+		}
+		if errs := Validate(req); len(errs) > 0 {
+			return nil, InvalidArgument("The request data is invalid").WithDetails(map[string]interface{}{
+				"fields": errs,
+			})
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewTypedHandler creates an HTTP handler from the typed handler
+func NewTypedHandler[Req any, Resp any](handler TypedHandler[Req, Resp]) http.HandlerFunc {
+	return NewHandler(Typed(handler))
+}
+
+// decodeData decodes the data into dest, the errors are invalid-argument errors
+// without the Go types, the fields of the wrong type are listed as FieldError
+func decodeData(data interface{}, dest interface{}) error {
+	if data == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(encoded, dest)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if err == nil {
+		return nil
+	} else if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return InvalidArgument("The request data is invalid").WithDetails(map[string]interface{}{
+			"fields": []FieldError{{Field: typeErr.Field, Message: fmt.Sprintf("must not be %v", typeErr.Value), Rule: "type"}},
+		})
+	}
+	return InvalidArgument("The request data is invalid")
+}
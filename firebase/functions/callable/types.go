@@ -1,9 +1,13 @@
 package callable
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/balesz/go/firebase/functions/logging"
 )
 
 //Handler -
@@ -13,15 +17,32 @@ type contextKey string
 
 //Context -
 type Context struct {
+	AppCheck   AppCheck
 	Auth       Auth
 	Data       interface{}
 	InstanceID string
-	URL        url.URL
+	Logger     *logging.Logger
+	// RemoteIP is the IP address of the client appended to X-Forwarded-For by the trusted
+	// proxies (see WithTrustedProxies), the hops set by the client are ignored
+	RemoteIP string
+	// Size is the size of the request body in bytes
+	Size int64
+	URL  url.URL
+
+	ctx context.Context
+}
+
+// Context returns the context of the request, or the background context
+func (it Context) Context() context.Context {
+	if it.ctx == nil {
+		return context.Background()
+	}
+	return it.ctx
 }
 
 func (it Context) String() string {
-	return fmt.Sprintf("Context { Auth: %v, Data: %v, InstanceID: %v, URL: %v }",
-		it.Auth, it.Data, it.InstanceID, it.URL)
+	return fmt.Sprintf("Context { AppCheck: %v, Auth: %v, Data: %v, InstanceID: %v, URL: %v }",
+		it.AppCheck, it.Auth, it.Data, it.InstanceID, it.URL)
 }
 
 //GetData - returns the data
@@ -38,25 +59,18 @@ func (it Context) GetData(data interface{}) error {
 
 //Auth -
 type Auth struct {
-	UID string
+	Token *auth.Token
+	UID   string
 }
 
 func (it Auth) String() string {
-	return fmt.Sprintf("Auth { UID: %v }", it.UID)
-}
-
-type httpsError struct {
-	Code          string         `json:"code"`
-	Details       string         `json:"details"`
-	HTTPErrorCode httpsErrorCode `json:"httpErrorCode"`
+	return fmt.Sprintf("Auth { UID: %v, SignInProvider: %v }", it.UID, it.SignInProvider())
 }
 
-type httpsErrorCode struct {
-	CanonicalName string `json:"canonicalName"`
-	Status        int    `json:"status"`
+type httpsCallableError struct {
+	Error *HttpsError `json:"error"`
 }
 
 type httpsCallableResult struct {
-	Error  httpsError  `json:"error"`
 	Result interface{} `json:"result"`
 }
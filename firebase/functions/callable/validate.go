@@ -0,0 +1,198 @@
+package callable
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var validateRegexps sync.Map
+
+// FieldError describes a field which failed the validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Rule    string `json:"rule"`
+}
+
+func (err FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", err.Field, err.Message)
+}
+
+// Validate checks the struct by its validate tags and returns every failed field.
+// The supported rules are required, min=N and max=N (value of numbers, length of
+// strings, slices and maps), enum=a|b|c and regex=PATTERN, which must be the last rule.
+// Nested structs, pointers and slices of structs are validated recursively.
+func Validate(value interface{}) []FieldError {
+	var errs []FieldError
+	validateValue(reflect.ValueOf(value), "", &errs)
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *[]FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			name := fieldName(sf)
+			if name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			field := v.Field(i)
+			for _, rule := range parseRules(sf.Tag.Get("validate")) {
+				if err := checkRule(field, rule); err != "" {
+					*errs = append(*errs, FieldError{Field: name, Message: err, Rule: rule.name})
+				}
+			}
+			validateValue(field, name, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%v[%v]", path, i), errs)
+		}
+	}
+}
+
+type validateRule struct {
+	arg  string
+	name string
+}
+
+func parseRules(tag string) []validateRule {
+	var rules []validateRule
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			rules = append(rules, validateRule{name: "regex", arg: strings.TrimPrefix(tag, "regex=")})
+			break
+		}
+		var part string
+		if index := strings.Index(tag, ","); index >= 0 {
+			part, tag = tag[:index], tag[index+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		rule := validateRule{name: part}
+		if index := strings.Index(part, "="); index >= 0 {
+			rule = validateRule{name: part[:index], arg: part[index+1:]}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// checkRule returns the message of the failed rule or empty string
+func checkRule(v reflect.Value, rule validateRule) string {
+	if rule.name == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch rule.name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(rule.arg, 64)
+		if err != nil {
+			return fmt.Sprintf("has invalid %v rule (%v)", rule.name, rule.arg)
+		}
+		value, unit, ok := measure(v)
+		if !ok {
+			return ""
+		}
+		switch {
+		case rule.name == "min" && value < limit && unit != "":
+			return fmt.Sprintf("must contain at least %v %v", rule.arg, unit)
+		case rule.name == "min" && value < limit:
+			return fmt.Sprintf("must be at least %v", rule.arg)
+		case rule.name == "max" && value > limit && unit != "":
+			return fmt.Sprintf("must contain at most %v %v", rule.arg, unit)
+		case rule.name == "max" && value > limit:
+			return fmt.Sprintf("must be at most %v", rule.arg)
+		}
+	case "enum":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Split(rule.arg, "|") {
+			if option == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %v", strings.ReplaceAll(rule.arg, "|", ", "))
+	case "regex":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		rx, err := compileRegexp(rule.arg)
+		if err != nil {
+			return fmt.Sprintf("has invalid regex rule (%v)", err)
+		} else if !rx.MatchString(v.String()) {
+			return fmt.Sprintf("must match %v", rule.arg)
+		}
+	default:
+		return fmt.Sprintf("has unknown rule (%v)", rule.name)
+	}
+	return ""
+}
+
+// measure gets the value of numbers or the length of strings, slices and maps with
+// the unit of the length (characters or items), ok is false if not applicable
+func measure(v reflect.Value) (value float64, unit string, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(len([]rune(v.String()))), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items", true
+	}
+	return 0, "", false
+}
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := validateRegexps.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	validateRegexps.Store(pattern, rx)
+	return rx, nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag != "" {
+		return tag
+	}
+	return sf.Name
+}
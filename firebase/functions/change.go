@@ -0,0 +1,178 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+var rxSimpleFieldName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// IsCreate reports whether the event is a document creation
+func (event FSEvent) IsCreate() bool {
+	return event.OldValue.Name == "" && event.Value.Name != ""
+}
+
+// IsUpdate reports whether the event is an update of an existing document
+func (event FSEvent) IsUpdate() bool {
+	return event.OldValue.Name != "" && event.Value.Name != ""
+}
+
+// IsDelete reports whether the event is a document deletion
+func (event FSEvent) IsDelete() bool {
+	return event.OldValue.Name != "" && event.Value.Name == ""
+}
+
+// Operation gets the operation of the event as one of FSTriggerCreate,
+// FSTriggerUpdate or FSTriggerDelete, write triggers are classified by the values
+func (event FSEvent) Operation(ctx context.Context) (string, error) {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch meta.EventType {
+	case FSTriggerCreate, FSTriggerUpdate, FSTriggerDelete:
+		return meta.EventType, nil
+	case FSTriggerWrite:
+		if event.IsCreate() {
+			return FSTriggerCreate, nil
+		} else if event.IsUpdate() {
+			return FSTriggerUpdate, nil
+		} else if event.IsDelete() {
+			return FSTriggerDelete, nil
+		}
+		return "", fmt.Errorf("The event has neither old nor new value")
+	}
+	return "", fmt.Errorf("Unexpected event type (%v)", meta.EventType)
+}
+
+// ChangedFields gets the sorted paths of the changed fields, nested map fields
+// are listed by their dot separated path (e.g. stats.wins)
+func (event FSEvent) ChangedFields() []string {
+	paths := diffFields(nil, event.OldValue.Fields, event.Value.Fields)
+	sort.Strings(paths)
+	return paths
+}
+
+// HasChanged reports whether the field at the path or any of its nested fields has changed
+func (event FSEvent) HasChanged(path string) bool {
+	before, _ := event.Before(path)
+	after, _ := event.After(path)
+	return !reflect.DeepEqual(before, after)
+}
+
+// Before gets the field at the path from the old value of the document
+func (event FSEvent) Before(path string) (FSEventField, bool) {
+	return event.OldValue.Field(path)
+}
+
+// After gets the field at the path from the new value of the document
+func (event FSEvent) After(path string) (FSEventField, bool) {
+	return event.Value.Field(path)
+}
+
+// BeforeTo decodes the field at the path of the old value to the given type
+func (event FSEvent) BeforeTo(path string, dest interface{}) error {
+	if err := event.OldValue.FieldTo(path, dest); err != nil {
+		return fmt.Errorf("BeforeTo: %v", err)
+	}
+	return nil
+}
+
+// AfterTo decodes the field at the path of the new value to the given type
+func (event FSEvent) AfterTo(path string, dest interface{}) error {
+	if err := event.Value.FieldTo(path, dest); err != nil {
+		return fmt.Errorf("AfterTo: %v", err)
+	}
+	return nil
+}
+
+// Field gets the field at the dot separated path
+func (val FSEventValue) Field(path string) (FSEventField, bool) {
+	fields := val.Fields
+	parts := splitFieldPath(path)
+	for i, part := range parts {
+		field, ok := fields[part]
+		if !ok {
+			return FSEventField{}, false
+		} else if i == len(parts)-1 {
+			return field, true
+		} else if field.AsMap == nil {
+			return FSEventField{}, false
+		}
+		fields = field.AsMap.Fields
+	}
+	return FSEventField{}, false
+}
+
+// FieldTo decodes the field at the dot separated path to the given type
+func (val FSEventValue) FieldTo(path string, dest interface{}) error {
+	field, ok := val.Field(path)
+	if !ok {
+		return fmt.Errorf("Field not found (%v)", path)
+	}
+	return field.DataTo(dest)
+}
+
+func diffFields(parent []string, old map[string]FSEventField, new map[string]FSEventField) []string {
+	keys := map[string]bool{}
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+
+	var paths []string
+	for key := range keys {
+		path := append(append([]string{}, parent...), key)
+		before, hasBefore := old[key]
+		after, hasAfter := new[key]
+		if hasBefore && hasAfter && before.AsMap != nil && after.AsMap != nil {
+			paths = append(paths, diffFields(path, before.AsMap.Fields, after.AsMap.Fields)...)
+		} else if hasBefore != hasAfter || !reflect.DeepEqual(before, after) {
+			paths = append(paths, joinFieldPath(path))
+		}
+	}
+	return paths
+}
+
+func joinFieldPath(parts []string) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		if rxSimpleFieldName.MatchString(part) {
+			quoted[i] = part
+		} else {
+			part = strings.ReplaceAll(part, `\`, `\\`)
+			quoted[i] = "`" + strings.ReplaceAll(part, "`", "\\`") + "`"
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+func splitFieldPath(path string) []string {
+	var parts []string
+	var part strings.Builder
+	quoted, escaped := false, false
+	for _, char := range path {
+		if escaped {
+			part.WriteRune(char)
+			escaped = false
+		} else if quoted && char == '\\' {
+			escaped = true
+		} else if char == '`' {
+			quoted = !quoted
+		} else if !quoted && char == '.' {
+			parts = append(parts, part.String())
+			part.Reset()
+		} else {
+			part.WriteRune(char)
+		}
+	}
+	return append(parts, part.String())
+}
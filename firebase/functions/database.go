@@ -1,5 +1,15 @@
 package functions
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
 // RTDBTriggerCreate : Triggered when new data is created in the Realtime Database.
 const RTDBTriggerCreate = "providers/google.firebase.database/eventTypes/ref.create"
 
@@ -11,3 +21,163 @@ const RTDBTriggerUpdate = "providers/google.firebase.database/eventTypes/ref.upd
 
 // RTDBTriggerWrite : Triggered on any mutation event: when data is created, updated, or deleted in the Realtime Database.
 const RTDBTriggerWrite = "providers/google.firebase.database/eventTypes/ref.write"
+
+// RTDBEvent is the payload of a legacy Realtime Database background function
+type RTDBEvent struct {
+	Auth     *RTDBEventAuth `json:"auth,omitempty"`
+	AuthType string         `json:"authType,omitempty"`
+	Data     interface{}    `json:"data"`
+	Delta    interface{}    `json:"delta"`
+}
+
+func (event RTDBEvent) String() string {
+	format := "RTDBEvent(AuthType: %v, Auth: %v, Data: %v, Delta: %v)"
+	return fmt.Sprintf(format, event.AuthType, event.Auth, event.Data, event.Delta)
+}
+
+// Before gets the data at the path before the change
+func (event RTDBEvent) Before() interface{} {
+	return event.Data
+}
+
+// After gets the data at the path after the change was applied
+func (event RTDBEvent) After() interface{} {
+	return applyDelta(event.Data, event.Delta)
+}
+
+// DataTo convert the data before the change to the given type
+func (event RTDBEvent) DataTo(dest interface{}) error {
+	if err := convertTo(event.Data, dest); err != nil {
+		return fmt.Errorf("DataTo: %v", err)
+	}
+	return nil
+}
+
+// DeltaTo convert the changed data to the given type
+func (event RTDBEvent) DeltaTo(dest interface{}) error {
+	if err := convertTo(event.Delta, dest); err != nil {
+		return fmt.Errorf("DeltaTo: %v", err)
+	}
+	return nil
+}
+
+// AfterTo convert the data after the change to the given type
+func (event RTDBEvent) AfterTo(dest interface{}) error {
+	if err := convertTo(event.After(), dest); err != nil {
+		return fmt.Errorf("AfterTo: %v", err)
+	}
+	return nil
+}
+
+// IsAdmin reports whether the change was made with admin privileges
+func (event RTDBEvent) IsAdmin() bool {
+	return event.AuthType == "ADMIN" || (event.Auth != nil && event.Auth.Admin)
+}
+
+// RTDBEventAuth is the auth information of the client that made the change
+type RTDBEventAuth struct {
+	Admin    bool                   `json:"admin"`
+	Variable map[string]interface{} `json:"variable,omitempty"`
+}
+
+func (auth RTDBEventAuth) String() string {
+	return fmt.Sprintf("RTDBEventAuth(Admin: %v, Variable: %v)", auth.Admin, auth.Variable)
+}
+
+// MockRTDBEvent MockRTDBEvent
+type MockRTDBEvent struct {
+	Admin    bool
+	New      interface{}
+	Old      interface{}
+	Resource string
+	Trigger  string
+	UID      string
+}
+
+// CreateContext creates context for testing
+func (evnt *MockRTDBEvent) CreateContext(base context.Context) (ctx context.Context, event RTDBEvent) {
+	old := evnt.unmarshal(evnt.Old)
+	ctx = metadata.NewContext(base, &metadata.Metadata{
+		EventID:   time.Now().UTC().Format(time.RFC3339),
+		EventType: evnt.Trigger,
+		Resource:  &metadata.Resource{RawPath: evnt.Resource},
+		Timestamp: time.Now(),
+	})
+	event = RTDBEvent{
+		Data:  old,
+		Delta: diffDelta(old, evnt.unmarshal(evnt.New)),
+	}
+	if evnt.Admin {
+		event.AuthType = "ADMIN"
+		event.Auth = &RTDBEventAuth{Admin: true}
+	} else if evnt.UID != "" {
+		event.AuthType = "USER"
+		event.Auth = &RTDBEventAuth{Variable: map[string]interface{}{"uid": evnt.UID}}
+	} else {
+		event.AuthType = "UNAUTHENTICATED"
+	}
+	return
+}
+
+func (evnt MockRTDBEvent) unmarshal(data interface{}) interface{} {
+	marshal, _ := json.Marshal(data)
+	var unmarshal interface{}
+	json.Unmarshal(marshal, &unmarshal)
+	return unmarshal
+}
+
+// applyDelta merges the delta into data, a nil value in the delta removes the child
+func applyDelta(data interface{}, delta interface{}) interface{} {
+	src, srcOk := data.(map[string]interface{})
+	change, changeOk := delta.(map[string]interface{})
+	if delta == nil {
+		return nil
+	} else if !srcOk || !changeOk {
+		return delta
+	}
+	result := map[string]interface{}{}
+	for key, val := range src {
+		result[key] = val
+	}
+	for key, val := range change {
+		if merged := applyDelta(result[key], val); merged == nil {
+			delete(result, key)
+		} else {
+			result[key] = merged
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// diffDelta computes the delta which turns old into new when applied
+func diffDelta(old interface{}, new interface{}) interface{} {
+	src, srcOk := old.(map[string]interface{})
+	dst, dstOk := new.(map[string]interface{})
+	if !srcOk || !dstOk {
+		return new
+	}
+	delta := map[string]interface{}{}
+	for key, val := range dst {
+		if !reflect.DeepEqual(src[key], val) {
+			delta[key] = diffDelta(src[key], val)
+		}
+	}
+	for key := range src {
+		if _, ok := dst[key]; !ok {
+			delta[key] = nil
+		}
+	}
+	return delta
+}
+
+func convertTo(data interface{}, dest interface{}) error {
+	if encoded, err := json.Marshal(data); err != nil {
+		return err
+	} else if err := json.Unmarshal(encoded, dest); err != nil {
+		return err
+	}
+	return nil
+}
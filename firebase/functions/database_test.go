@@ -0,0 +1,67 @@
+package functions_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/balesz/go/firebase/functions"
+)
+
+func TestRTDBEventAfter(t *testing.T) {
+	var event functions.RTDBEvent
+	payload := `{
+		"authType": "USER",
+		"auth": {"admin": false, "variable": {"uid": "alice"}},
+		"data": {"name": "Alice", "score": 1, "stats": {"wins": 1, "losses": 2}},
+		"delta": {"score": 2, "stats": {"losses": null}, "online": true}
+	}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"name": "Alice", "score": 2.0, "online": true,
+		"stats": map[string]interface{}{"wins": 1.0},
+	}
+	if got := event.After(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v != %v", got, want)
+	} else if event.IsAdmin() {
+		t.Error("the event is not admin")
+	}
+
+	var after struct {
+		Score int `json:"score"`
+		Stats struct {
+			Losses int `json:"losses"`
+		} `json:"stats"`
+	}
+	if err := event.AfterTo(&after); err != nil {
+		t.Error(err)
+	} else if after.Score != 2 || after.Stats.Losses != 0 {
+		t.Errorf("after: %+v", after)
+	}
+}
+
+func TestMockRTDBEvent(t *testing.T) {
+	mock := functions.MockRTDBEvent{
+		Old:      map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": true, "d": "x"}},
+		New:      map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": false}, "e": "y"},
+		Resource: "projects/_/instances/test/refs/users/alice",
+		Trigger:  functions.RTDBTriggerUpdate,
+		Admin:    true,
+	}
+
+	ctx, event := mock.CreateContext(context.Background())
+	if path, err := functions.GetPath(ctx); err != nil || path != "/users/alice" {
+		t.Errorf("path: %v %v", path, err)
+	}
+
+	want := map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"c": false}, "e": "y"}
+	if got := event.After(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v != %v", got, want)
+	} else if !event.IsAdmin() {
+		t.Error("the event is admin")
+	}
+}
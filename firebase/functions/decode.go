@@ -0,0 +1,481 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/balesz/go/firebase"
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+var (
+	typeOfByteSlice   = reflect.TypeOf([]byte{})
+	typeOfDocumentRef = reflect.TypeOf((*firestore.DocumentRef)(nil))
+	typeOfGoTime      = reflect.TypeOf(time.Time{})
+	typeOfLatLng      = reflect.TypeOf((*latlng.LatLng)(nil))
+)
+
+var structFieldCache sync.Map
+
+type structField struct {
+	Index     []int
+	Name      string
+	OmitEmpty bool
+}
+
+// decoder decodes the fields, the references are resolved with the Firestore client of the clients
+type decoder struct {
+	clients *firebase.Clients
+}
+
+// DataTo decodes the field into dest following the rules of DocumentSnapshot.DataTo,
+// the references are resolved with the default clients, see DataToWithClients. Unlike
+// DocumentSnapshot.DataTo, the references decoded into interface{} are their resource
+// names if the Firestore client of the default clients is not available.
+func (field FSEventField) DataTo(dest interface{}) error {
+	return field.DataToWithClients(dest, nil)
+}
+
+// DataToWithClients decodes the field into dest following the rules of DocumentSnapshot.DataTo,
+// the references are *firestore.DocumentRef of the Firestore client of the clients. Unlike
+// DocumentSnapshot.DataTo, the references decoded into interface{} are their resource names
+// if the Firestore client is not available, the *firestore.DocumentRef targets require it.
+func (field FSEventField) DataToWithClients(dest interface{}, clients *firebase.Clients) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("nil or not a pointer")
+	}
+	return decoder{clients: clients}.setReflectFromField(v.Elem(), field)
+}
+
+// setReflectFromField sets v from a Firestore field. v must be a settable value.
+func (d decoder) setReflectFromField(v reflect.Value, field FSEventField) error {
+	typeErr := func() error {
+		return fmt.Errorf("cannot set type %s to %s", v.Type(), field.typeString())
+	}
+
+	// A null value sets anything nullable to nil, and has no effect on anything else.
+	if field.IsNull {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Type() {
+	case typeOfByteSlice:
+		if field.AsBytes == nil {
+			return typeErr()
+		}
+		v.SetBytes(field.AsBytes)
+		return nil
+	case typeOfGoTime:
+		if field.AsTimestamp == nil {
+			return typeErr()
+		}
+		v.Set(reflect.ValueOf(*field.AsTimestamp))
+		return nil
+	case typeOfLatLng:
+		if field.AsGeoPoint == nil {
+			return typeErr()
+		}
+		v.Set(reflect.ValueOf(field.AsGeoPoint))
+		return nil
+	case typeOfDocumentRef:
+		if field.AsReference == nil {
+			return typeErr()
+		}
+		ref, err := d.referenceToDoc(*field.AsReference)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(ref))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if field.AsBoolean == nil {
+			return typeErr()
+		}
+		v.SetBool(*field.AsBoolean)
+
+	case reflect.String:
+		if field.AsString == nil {
+			return typeErr()
+		}
+		v.SetString(*field.AsString)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if field.AsInteger != nil {
+			i = *field.AsInteger
+		} else if field.AsDouble != nil {
+			if i = int64(*field.AsDouble); float64(i) != *field.AsDouble {
+				return fmt.Errorf("float %f does not fit into %s", *field.AsDouble, v.Type())
+			}
+		} else {
+			return typeErr()
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("value %v overflows type %s", i, v.Type())
+		}
+		v.SetInt(i)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		var u uint64
+		if field.AsInteger != nil {
+			if *field.AsInteger < 0 {
+				return fmt.Errorf("value %v overflows type %s", *field.AsInteger, v.Type())
+			}
+			u = uint64(*field.AsInteger)
+		} else if field.AsDouble != nil {
+			if u = uint64(*field.AsDouble); float64(u) != *field.AsDouble {
+				return fmt.Errorf("float %f does not fit into %s", *field.AsDouble, v.Type())
+			}
+		} else {
+			return typeErr()
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("value %v overflows type %s", u, v.Type())
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if field.AsDouble != nil {
+			f = *field.AsDouble
+		} else if field.AsInteger != nil {
+			if f = float64(*field.AsInteger); int64(f) != *field.AsInteger {
+				return fmt.Errorf("value %v overflows type %s", *field.AsInteger, v.Type())
+			}
+		} else {
+			return typeErr()
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows type %s", f, v.Type())
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		if field.AsArray == nil {
+			return typeErr()
+		}
+		values := field.AsArray.Values
+		if v.Len() < len(values) {
+			v.Set(reflect.MakeSlice(v.Type(), len(values), len(values)))
+		} else if v.Len() > len(values) {
+			v.SetLen(len(values))
+		}
+		return d.populateRepeated(v, values, len(values))
+
+	case reflect.Array:
+		if field.AsArray == nil {
+			return typeErr()
+		}
+		values := field.AsArray.Values
+		n := v.Len()
+		if n > len(values) {
+			zero := reflect.Zero(v.Type().Elem())
+			for i := len(values); i < n; i++ {
+				v.Index(i).Set(zero)
+			}
+			n = len(values)
+		}
+		return d.populateRepeated(v, values, n)
+
+	case reflect.Map:
+		if field.AsMap == nil {
+			return typeErr()
+		}
+		return d.populateMap(v, field.AsMap.Fields)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.setReflectFromField(v.Elem(), field)
+
+	case reflect.Struct:
+		if field.AsMap == nil {
+			return typeErr()
+		}
+		return d.populateStruct(v, field.AsMap.Fields)
+
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			if !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+				return d.setReflectFromField(v.Elem(), field)
+			}
+			value, err := d.createFromField(field)
+			if err != nil {
+				return err
+			}
+			if value == nil {
+				v.Set(reflect.Zero(v.Type()))
+			} else {
+				v.Set(reflect.ValueOf(value))
+			}
+			return nil
+		}
+		fallthrough
+
+	default:
+		return fmt.Errorf("cannot set type %s", v.Type())
+	}
+	return nil
+}
+
+func (d decoder) populateRepeated(v reflect.Value, values []FSEventField, n int) error {
+	for i := 0; i < n; i++ {
+		if err := d.setReflectFromField(v.Index(i), values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d decoder) populateMap(v reflect.Value, fields map[string]FSEventField) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("map key type is not string")
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for key, field := range fields {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.setReflectFromField(elem, field); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+	}
+	return nil
+}
+
+func (d decoder) populateStruct(v reflect.Value, fields map[string]FSEventField) error {
+	list, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for key, field := range fields {
+		f := matchField(list, key)
+		if f == nil {
+			continue
+		}
+		if err := d.setReflectFromField(fieldByIndex(v, f.Index), field); err != nil {
+			return fmt.Errorf("%s.%s: %v", v.Type(), f.Name, err)
+		}
+	}
+	return nil
+}
+
+// createFromField creates a fresh Go value like DocumentSnapshot.Data does, the
+// references are their resource names if no Firestore client is available
+func (d decoder) createFromField(field FSEventField) (interface{}, error) {
+	if field.AsReference != nil {
+		if _, err := d.clients.Resolve().GetFirestore(context.Background()); err != nil {
+			return *field.AsReference, nil
+		}
+		return d.referenceToDoc(*field.AsReference)
+	} else if field.AsArray != nil {
+		values := make([]interface{}, len(field.AsArray.Values))
+		for i, item := range field.AsArray.Values {
+			value, err := d.createFromField(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	} else if field.AsMap != nil {
+		values := make(map[string]interface{}, len(field.AsMap.Fields))
+		for key, item := range field.AsMap.Fields {
+			value, err := d.createFromField(item)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+	return field.Value(), nil
+}
+
+// referenceToDoc converts a referenceValue to a DocumentRef of the Firestore client,
+// the reference must be in the project and the database of the client
+func (d decoder) referenceToDoc(reference string) (*firestore.DocumentRef, error) {
+	name, err := ParseResourceName(reference)
+	if err != nil || !name.IsFirestore() || name.Path == "" {
+		return nil, fmt.Errorf("malformed document path %q", reference)
+	} else if len(strings.Split(name.Path, "/"))%2 != 0 {
+		return nil, fmt.Errorf("path %q refers to collection, not document", reference)
+	}
+	client, err := d.clients.Resolve().GetFirestore(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	ref := client.Doc(name.Path)
+	if want := fmt.Sprintf("projects/%v/databases/%v/documents/%v", name.Project, name.Database, name.Path); ref.Path != want {
+		return nil, fmt.Errorf("reference %q is not in the database of the Firestore client (%v)", reference, ref.Path)
+	}
+	return ref, nil
+}
+
+func (field FSEventField) typeString() string {
+	if field.IsNull {
+		return "null"
+	} else if field.AsArray != nil {
+		return "array"
+	} else if field.AsBoolean != nil {
+		return "bool"
+	} else if field.AsBytes != nil {
+		return "bytes"
+	} else if field.AsDouble != nil {
+		return "float"
+	} else if field.AsGeoPoint != nil {
+		return "GeoPoint"
+	} else if field.AsInteger != nil {
+		return "int"
+	} else if field.AsMap != nil {
+		return "map"
+	} else if field.AsReference != nil {
+		return "reference"
+	} else if field.AsString != nil {
+		return "string"
+	} else if field.AsTimestamp != nil {
+		return "timestamp"
+	}
+	return "<unknown Value type>"
+}
+
+// fieldByIndex returns the nested field and allocates nil embedded pointers on the way
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// matchField returns the field with the exact name, or the first one matching case-insensitively
+func matchField(list []structField, name string) *structField {
+	var fold *structField
+	for i := range list {
+		if list[i].Name == name {
+			return &list[i]
+		} else if fold == nil && strings.EqualFold(list[i].Name, name) {
+			fold = &list[i]
+		}
+	}
+	return fold
+}
+
+// structFields lists the fields of t by their firestore names, promoting untagged embedded structs
+func structFields(t reflect.Type) ([]structField, error) {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.([]structField), nil
+	}
+
+	type candidate struct {
+		structField
+		tagged bool
+	}
+
+	var candidates []candidate
+	var walk func(st reflect.Type, parent []int, visited map[reflect.Type]bool) error
+	walk = func(st reflect.Type, parent []int, visited map[reflect.Type]bool) error {
+		if visited[st] {
+			return nil
+		}
+		visited[st] = true
+		defer delete(visited, st)
+
+		for i := 0; i < st.NumField(); i++ {
+			sf := st.Field(i)
+			tag := sf.Tag.Get("firestore")
+			if tag == "-" {
+				continue
+			}
+			name, err := parseTag(tag)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", st, sf.Name, err)
+			}
+
+			index := append(append([]int{}, parent...), i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != typeOfGoTime {
+				if err := walk(ft, index, visited); err != nil {
+					return err
+				}
+				continue
+			} else if sf.PkgPath != "" {
+				continue
+			}
+
+			omitEmpty := strings.Contains(tag+",", ",omitempty,")
+			if name == "" {
+				candidates = append(candidates, candidate{structField{Index: index, Name: sf.Name, OmitEmpty: omitEmpty}, false})
+			} else {
+				candidates = append(candidates, candidate{structField{Index: index, Name: name, OmitEmpty: omitEmpty}, true})
+			}
+		}
+		return nil
+	}
+
+	if err := walk(t, nil, map[reflect.Type]bool{}); err != nil {
+		return nil, err
+	}
+
+	// Shallower fields dominate; conflicts at the same depth are dropped unless exactly one is tagged.
+	var list []structField
+	for _, c := range candidates {
+		dominant, conflict := true, false
+		for _, o := range candidates {
+			if o.Name != c.Name || reflect.DeepEqual(o.Index, c.Index) {
+				continue
+			} else if len(o.Index) < len(c.Index) {
+				dominant = false
+			} else if len(o.Index) == len(c.Index) {
+				if o.tagged && !c.tagged {
+					dominant = false
+				} else if o.tagged == c.tagged {
+					conflict = true
+				}
+			}
+		}
+		if dominant && !conflict {
+			list = append(list, c.structField)
+		}
+	}
+
+	structFieldCache.Store(t, list)
+	return list, nil
+}
+
+// parseTag returns the name of a firestore struct tag and validates its options
+func parseTag(tag string) (string, error) {
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty", "serverTimestamp":
+		default:
+			return "", fmt.Errorf("unknown tag option: %q", opt)
+		}
+	}
+	return parts[0], nil
+}
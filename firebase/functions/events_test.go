@@ -0,0 +1,72 @@
+package functions_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/balesz/go/firebase/functions"
+)
+
+func TestParseAuthEvent(t *testing.T) {
+	mock := functions.MockAuthEvent{Trigger: functions.AuthTriggerCreate}
+	ctx, _ := mock.CreateContext(context.Background())
+
+	payload := `{
+		"uid": "alice", "email": "alice@example.com", "emailVerified": true,
+		"metadata": {"createdAt": "2020-11-20T10:00:00Z"},
+		"providerData": [{"providerId": "google.com", "uid": "123"}]
+	}`
+	if event, err := functions.ParseAuthEvent(ctx, []byte(payload)); err != nil {
+		t.Error(err)
+	} else if event.UID != "alice" || !event.EmailVerified || event.Metadata.CreatedAt.Year() != 2020 {
+		t.Errorf("event: %v", event)
+	} else if len(event.ProviderData) != 1 || event.ProviderData[0].ProviderID != "google.com" {
+		t.Errorf("providerData: %v", event.ProviderData)
+	}
+
+	if _, err := functions.ParsePubSubMessage(ctx, []byte(payload)); err == nil {
+		t.Error("the event type must be checked")
+	}
+}
+
+func TestParsePubSubMessage(t *testing.T) {
+	mock := functions.MockPubSubMessage{Topic: "jobs"}
+	ctx, _ := mock.CreateContext(context.Background())
+
+	payload := `{"attributes": {"job": "leaderboard"}, "data": "eyJsaW1pdCI6MTB9"}`
+	var data struct {
+		Limit int `json:"limit"`
+	}
+	if msg, err := functions.ParsePubSubMessage(ctx, []byte(payload)); err != nil {
+		t.Error(err)
+	} else if msg.Attributes["job"] != "leaderboard" || msg.Text() != `{"limit":10}` {
+		t.Errorf("msg: %v", msg)
+	} else if err := msg.DataTo(&data); err != nil || data.Limit != 10 {
+		t.Errorf("data: %v %v", data, err)
+	}
+}
+
+func TestParseStorageObjectEvent(t *testing.T) {
+	mock := functions.MockStorageObjectEvent{Trigger: functions.StorageTriggerFinalize}
+	ctx, _ := mock.CreateContext(context.Background())
+
+	payload := `{
+		"bucket": "uploads", "name": "avatars/alice.png", "size": "2048",
+		"contentType": "image/png", "generation": "1605866400000000",
+		"metageneration": "1", "metadata": {"owner": "alice"}
+	}`
+	if event, err := functions.ParseStorageObjectEvent(ctx, []byte(payload)); err != nil {
+		t.Error(err)
+	} else if event.Bucket != "uploads" || event.Size != 2048 || event.Metadata["owner"] != "alice" {
+		t.Errorf("event: %v", event)
+	}
+
+	mock.Object = functions.StorageObjectEvent{Bucket: "uploads", ID: "custom", Name: "a.png", Generation: 1}
+	if _, event := mock.CreateContext(context.Background()); event.ID != "custom" {
+		t.Errorf("id: %v", event.ID)
+	}
+	mock.Object.ID = ""
+	if _, event := mock.CreateContext(context.Background()); event.ID != "uploads/a.png/1" {
+		t.Errorf("generated id: %v", event.ID)
+	}
+}
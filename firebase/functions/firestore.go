@@ -1,13 +1,20 @@
 package functions
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
+	"reflect"
+	"strconv"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+	"google.golang.org/genproto/googleapis/type/latlng"
 )
 
 // FSTriggerCreate : Triggered when a document is written to for the first time.
@@ -47,31 +54,131 @@ func (val FSEventValue) String() string {
 	return fmt.Sprintf(format, val.CreateTime, val.Fields, val.Name, val.Name)
 }
 
-// DataTo convert FSEventValue to the given type
-func (val FSEventValue) DataTo(dest interface{}) {
-	var data = map[string]interface{}{}
-	for k, v := range val.Fields {
-		data[k] = v.Value()
+// DataTo populates dest from the fields of the document following the
+// rules of firestore.DocumentSnapshot.DataTo, including the firestore struct tags.
+// Unlike DocumentSnapshot.DataTo, the references decoded into interface{} are their
+// resource names if the Firestore client of the default clients is not available.
+func (val FSEventValue) DataTo(dest interface{}) error {
+	return val.DataToWithClients(dest, nil)
+}
+
+// DataToWithClients populates dest like DataTo, the references are resolved with the
+// Firestore client of the clients, they are resource names in interface{} without it
+func (val FSEventValue) DataToWithClients(dest interface{}, clients *firebase.Clients) error {
+	field := FSEventField{AsMap: &FSEventMap{Fields: val.Fields}}
+	if val.Fields == nil {
+		field.AsMap.Fields = map[string]FSEventField{}
 	}
-	encoded, _ := json.Marshal(data)
-	json.Unmarshal(encoded, dest)
+	if err := field.DataToWithClients(dest, clients); err != nil {
+		return fmt.Errorf("DataTo: %v", err)
+	}
+	return nil
 }
 
-// FSEventField FSEventField
+// FSEventField is a Firestore value in the REST representation
 type FSEventField struct {
-	AsBoolean   *bool      `json:"booleanValue,omitempty"`
-	AsString    *string    `json:"stringValue,omitempty"`
-	AsTimestamp *time.Time `json:"timestampValue,omitempty"`
+	AsArray     *FSEventArray  `json:"arrayValue,omitempty"`
+	AsBoolean   *bool          `json:"booleanValue,omitempty"`
+	AsBytes     []byte         `json:"bytesValue,omitempty"`
+	AsDouble    *float64       `json:"doubleValue,omitempty"`
+	AsGeoPoint  *latlng.LatLng `json:"geoPointValue,omitempty"`
+	AsInteger   *int64         `json:"integerValue,string,omitempty"`
+	AsMap       *FSEventMap    `json:"mapValue,omitempty"`
+	AsReference *string        `json:"referenceValue,omitempty"`
+	AsString    *string        `json:"stringValue,omitempty"`
+	AsTimestamp *time.Time     `json:"timestampValue,omitempty"`
+	IsNull      bool           `json:"-"`
 }
 
 func (field FSEventField) String() string {
 	return fmt.Sprintf("%v", field.Value())
 }
 
+// UnmarshalJSON decodes the field and detects the nullValue key, the doubleValue
+// may be a string like "NaN", "Infinity" or "-Infinity"
+func (field *FSEventField) UnmarshalJSON(data []byte) error {
+	type plain FSEventField
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	var double *float64
+	if raw, ok := keys["doubleValue"]; ok && len(raw) > 0 && raw[0] == '"' {
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return err
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("doubleValue is invalid (%v)", str)
+		}
+		double = &value
+		delete(keys, "doubleValue")
+		if data, err = json.Marshal(keys); err != nil {
+			return err
+		}
+	}
+
+	if err := json.Unmarshal(data, (*plain)(field)); err != nil {
+		return err
+	} else if double != nil {
+		field.AsDouble = double
+	}
+	_, field.IsNull = keys["nullValue"]
+	return nil
+}
+
+// MarshalJSON encodes the field and emits the nullValue key if needed, the
+// doubleValue of NaN and the infinities is encoded as string
+func (field FSEventField) MarshalJSON() ([]byte, error) {
+	type plain FSEventField
+	if field.IsNull {
+		return []byte(`{"nullValue":null}`), nil
+	} else if field.AsDouble == nil || !(math.IsNaN(*field.AsDouble) || math.IsInf(*field.AsDouble, 0)) {
+		return json.Marshal(plain(field))
+	}
+
+	var double string
+	switch value := *field.AsDouble; {
+	case math.IsNaN(value):
+		double = "NaN"
+	case math.IsInf(value, 1):
+		double = "Infinity"
+	default:
+		double = "-Infinity"
+	}
+	field.AsDouble = nil
+	encoded, err := json.Marshal(plain(field))
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &keys); err != nil {
+		return nil, err
+	}
+	keys["doubleValue"], _ = json.Marshal(double)
+	return json.Marshal(keys)
+}
+
 // Value gets the dynamic value of the field
 func (field FSEventField) Value() interface{} {
-	if field.AsBoolean != nil {
+	if field.AsArray != nil {
+		return field.AsArray.Value()
+	} else if field.AsBoolean != nil {
 		return *field.AsBoolean
+	} else if field.AsBytes != nil {
+		return field.AsBytes
+	} else if field.AsDouble != nil {
+		return *field.AsDouble
+	} else if field.AsGeoPoint != nil {
+		return field.AsGeoPoint
+	} else if field.AsInteger != nil {
+		return *field.AsInteger
+	} else if field.AsMap != nil {
+		return field.AsMap.Value()
+	} else if field.AsReference != nil {
+		return *field.AsReference
 	} else if field.AsString != nil {
 		return *field.AsString
 	} else if field.AsTimestamp != nil {
@@ -80,6 +187,174 @@ func (field FSEventField) Value() interface{} {
 	return nil
 }
 
+// NewFSEventField creates a field from the given Go value, see ToFSEventField.
+// It panics if the value can not be stored in Firestore.
+func NewFSEventField(value interface{}) FSEventField {
+	field, err := ToFSEventField(value)
+	if err != nil {
+		panic(fmt.Sprintf("NewFSEventField: %v", err))
+	}
+	return field
+}
+
+// ToFSEventField converts the Go value to a field like the Firestore client does:
+// the slices, arrays and string-keyed maps are converted recursively, the structs by
+// their firestore struct tags, *firestore.DocumentRef to reference and the unsigned
+// integers above the int64 range to double. The other values return an error.
+func ToFSEventField(value interface{}) (FSEventField, error) {
+	switch val := value.(type) {
+	case nil:
+		return FSEventField{IsNull: true}, nil
+	case []byte:
+		return FSEventField{AsBytes: val}, nil
+	case json.Number:
+		if num, err := val.Int64(); err == nil {
+			return FSEventField{AsInteger: &num}, nil
+		} else if num, err := val.Float64(); err == nil {
+			return FSEventField{AsDouble: &num}, nil
+		}
+		str := val.String()
+		return FSEventField{AsString: &str}, nil
+	case time.Time:
+		return FSEventField{AsTimestamp: &val}, nil
+	case *latlng.LatLng:
+		if val == nil {
+			return FSEventField{IsNull: true}, nil
+		}
+		return FSEventField{AsGeoPoint: val}, nil
+	case *firestore.DocumentRef:
+		if val == nil {
+			return FSEventField{IsNull: true}, nil
+		}
+		return FSEventField{AsReference: &val.Path}, nil
+	}
+	return reflectToField(reflect.ValueOf(value))
+}
+
+// reflectToField converts the kinds of the values which are not handled by ToFSEventField
+func reflectToField(v reflect.Value) (FSEventField, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		val := v.Bool()
+		return FSEventField{AsBoolean: &val}, nil
+	case reflect.String:
+		val := v.String()
+		return FSEventField{AsString: &val}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val := v.Int()
+		return FSEventField{AsInteger: &val}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val := v.Uint(); val <= math.MaxInt64 {
+			num := int64(val)
+			return FSEventField{AsInteger: &num}, nil
+		}
+		val := float64(v.Uint())
+		return FSEventField{AsDouble: &val}, nil
+	case reflect.Float32, reflect.Float64:
+		val := v.Float()
+		return FSEventField{AsDouble: &val}, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return FSEventField{IsNull: true}, nil
+		}
+		return ToFSEventField(v.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return FSEventField{IsNull: true}, nil
+		} else if v.Type().Elem().Kind() == reflect.Uint8 {
+			bytes := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(bytes), v)
+			return FSEventField{AsBytes: bytes}, nil
+		}
+		array := FSEventArray{Values: []FSEventField{}}
+		for i := 0; i < v.Len(); i++ {
+			item, err := ToFSEventField(v.Index(i).Interface())
+			if err != nil {
+				return FSEventField{}, fmt.Errorf("[%v]: %v", i, err)
+			}
+			array.Values = append(array.Values, item)
+		}
+		return FSEventField{AsArray: &array}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return FSEventField{}, fmt.Errorf("map key type %s is not string", v.Type().Key())
+		} else if v.IsNil() {
+			return FSEventField{IsNull: true}, nil
+		}
+		fields := map[string]FSEventField{}
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := ToFSEventField(iter.Value().Interface())
+			if err != nil {
+				return FSEventField{}, fmt.Errorf("%v: %v", iter.Key().String(), err)
+			}
+			fields[iter.Key().String()] = item
+		}
+		return FSEventField{AsMap: &FSEventMap{Fields: fields}}, nil
+	case reflect.Struct:
+		list, err := structFields(v.Type())
+		if err != nil {
+			return FSEventField{}, err
+		}
+		fields := map[string]FSEventField{}
+		for _, f := range list {
+			fv, ok := structFieldValue(v, f.Index)
+			if !ok || !fv.CanInterface() || (f.OmitEmpty && fv.IsZero()) {
+				continue
+			}
+			item, err := ToFSEventField(fv.Interface())
+			if err != nil {
+				return FSEventField{}, fmt.Errorf("%s.%s: %v", v.Type(), f.Name, err)
+			}
+			fields[f.Name] = item
+		}
+		return FSEventField{AsMap: &FSEventMap{Fields: fields}}, nil
+	}
+	return FSEventField{}, fmt.Errorf("cannot convert type %s", v.Type())
+}
+
+// structFieldValue returns the nested field, it is false if an embedded pointer on the way is nil
+func structFieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// FSEventArray is the arrayValue of a Firestore field
+type FSEventArray struct {
+	Values []FSEventField `json:"values"`
+}
+
+// Value gets the dynamic values of the array
+func (array FSEventArray) Value() []interface{} {
+	values := make([]interface{}, len(array.Values))
+	for i, val := range array.Values {
+		values[i] = val.Value()
+	}
+	return values
+}
+
+// FSEventMap is the mapValue of a Firestore field
+type FSEventMap struct {
+	Fields map[string]FSEventField `json:"fields"`
+}
+
+// Value gets the dynamic values of the map
+func (mapValue FSEventMap) Value() map[string]interface{} {
+	values := make(map[string]interface{}, len(mapValue.Fields))
+	for key, val := range mapValue.Fields {
+		values[key] = val.Value()
+	}
+	return values
+}
+
 // FSEventUpdateMask FSEventUpdateMask
 type FSEventUpdateMask struct {
 	FieldPaths []string `json:"fieldPaths"`
@@ -121,21 +396,21 @@ func (evnt *MockEvent) CreateContext(base context.Context) (ctx context.Context,
 func (evnt MockEvent) unmarshal(data interface{}) map[string]interface{} {
 	marshal, _ := json.Marshal(data)
 	var unmarshal map[string]interface{}
-	json.Unmarshal(marshal, &unmarshal)
+	decoder := json.NewDecoder(bytes.NewReader(marshal))
+	decoder.UseNumber()
+	decoder.Decode(&unmarshal)
 	return unmarshal
 }
 
 func (evnt MockEvent) eventValue(doc map[string]interface{}) FSEventValue {
+	if doc == nil {
+		return FSEventValue{}
+	}
+
 	var fields = map[string]FSEventField{}
 
 	for key, value := range doc {
-		if val, ok := value.(bool); ok {
-			fields[key] = FSEventField{AsBoolean: &val}
-		} else if val, ok := value.(string); ok {
-			fields[key] = FSEventField{AsString: &val}
-		} else if val, ok := value.(time.Time); ok {
-			fields[key] = FSEventField{AsTimestamp: &val}
-		}
+		fields[key] = NewFSEventField(value)
 	}
 
 	return FSEventValue{
@@ -150,13 +425,13 @@ func (evnt MockEvent) eventUpdateMask() FSEventUpdateMask {
 	var keys = map[string]bool{}
 
 	for key, val := range evnt._old {
-		if evnt._new[key] != val {
+		if !reflect.DeepEqual(evnt._new[key], val) {
 			keys[key] = true
 		}
 	}
 
 	for key, val := range evnt._new {
-		if evnt._old[key] != val {
+		if !reflect.DeepEqual(evnt._old[key], val) {
 			keys[key] = true
 		}
 	}
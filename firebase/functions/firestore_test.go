@@ -0,0 +1,344 @@
+package functions_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/balesz/go/firebase"
+	"github.com/balesz/go/firebase/functions"
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+const fsEventPayload = `{
+	"oldValue": {},
+	"value": {
+		"name": "projects/test/databases/(default)/documents/users/alice",
+		"fields": {
+			"name": {"stringValue": "Alice"},
+			"active": {"booleanValue": true},
+			"score": {"integerValue": "9007199254740993"},
+			"ratio": {"doubleValue": 0.25},
+			"avatar": {"bytesValue": "aGVsbG8="},
+			"deleted": {"nullValue": null},
+			"joined": {"timestampValue": "2020-11-20T10:00:00Z"},
+			"team": {"referenceValue": "projects/test/databases/(default)/documents/teams/red"},
+			"home": {"geoPointValue": {"latitude": 47.5, "longitude": 19.05}},
+			"tags": {"arrayValue": {"values": [{"stringValue": "a"}, {"integerValue": "2"}]}},
+			"stats": {"mapValue": {"fields": {"wins": {"integerValue": "3"}, "nested": {"mapValue": {"fields": {"ok": {"booleanValue": false}}}}}}}
+		}
+	}
+}`
+
+func TestFSEventFieldValue(t *testing.T) {
+	var event functions.FSEvent
+	if err := json.Unmarshal([]byte(fsEventPayload), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := event.Value.Fields
+	stats := fields["stats"].Value().(map[string]interface{})
+	tags := fields["tags"].Value().([]interface{})
+
+	if got := fields["name"].Value(); got != "Alice" {
+		t.Errorf("name: %v", got)
+	} else if got := fields["active"].Value(); got != true {
+		t.Errorf("active: %v", got)
+	} else if got := fields["score"].Value(); got != int64(9007199254740993) {
+		t.Errorf("score: %v", got)
+	} else if got := fields["ratio"].Value(); got != 0.25 {
+		t.Errorf("ratio: %v", got)
+	} else if got := fields["avatar"].Value(); string(got.([]byte)) != "hello" {
+		t.Errorf("avatar: %v", got)
+	} else if got := fields["deleted"]; !got.IsNull || got.Value() != nil {
+		t.Errorf("deleted: %v", got)
+	} else if got := fields["joined"].Value(); !got.(time.Time).Equal(time.Date(2020, 11, 20, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("joined: %v", got)
+	} else if got := fields["team"].Value(); got != "projects/test/databases/(default)/documents/teams/red" {
+		t.Errorf("team: %v", got)
+	} else if got := fields["home"].Value().(*latlng.LatLng); got.Latitude != 47.5 || got.Longitude != 19.05 {
+		t.Errorf("home: %v", got)
+	} else if len(tags) != 2 || tags[0] != "a" || tags[1] != int64(2) {
+		t.Errorf("tags: %v", tags)
+	} else if stats["wins"] != int64(3) || stats["nested"].(map[string]interface{})["ok"] != false {
+		t.Errorf("stats: %v", stats)
+	}
+}
+
+func TestFSEventFieldMarshal(t *testing.T) {
+	field := functions.NewFSEventField(map[string]interface{}{
+		"count": 7,
+		"empty": nil,
+		"list":  []interface{}{1.5, "x"},
+	})
+
+	var decoded functions.FSEventField
+	if encoded, err := json.Marshal(field); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	value := decoded.Value().(map[string]interface{})
+	if value["count"] != int64(7) {
+		t.Errorf("count: %v", value["count"])
+	} else if val, ok := value["empty"]; !ok || val != nil || !decoded.AsMap.Fields["empty"].IsNull {
+		t.Errorf("empty: %v", val)
+	} else if list := value["list"].([]interface{}); list[0] != 1.5 || list[1] != "x" {
+		t.Errorf("list: %v", list)
+	}
+}
+
+func TestFSEventFieldSpecialDouble(t *testing.T) {
+	var fields map[string]functions.FSEventField
+	payload := `{"nan": {"doubleValue": "NaN"}, "inf": {"doubleValue": "Infinity"}, "ninf": {"doubleValue": "-Infinity"}}`
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := fields["nan"].Value().(float64); !math.IsNaN(value) {
+		t.Errorf("nan: %v", value)
+	} else if value := fields["inf"].Value().(float64); !math.IsInf(value, 1) {
+		t.Errorf("inf: %v", value)
+	} else if value := fields["ninf"].Value().(float64); !math.IsInf(value, -1) {
+		t.Errorf("ninf: %v", value)
+	}
+
+	if encoded, err := json.Marshal(fields["ninf"]); err != nil {
+		t.Error(err)
+	} else if string(encoded) != `{"doubleValue":"-Infinity"}` {
+		t.Errorf("encoded: %s", encoded)
+	}
+
+	var invalid functions.FSEventField
+	if err := json.Unmarshal([]byte(`{"doubleValue": "x"}`), &invalid); err == nil {
+		t.Error("invalid double must return an error")
+	}
+}
+
+func TestToFSEventField(t *testing.T) {
+	type Team struct {
+		Name  string `firestore:"name"`
+		Note  string `firestore:"note,omitempty"`
+		Level uint8
+	}
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	client, err := firestore.NewClient(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	field := functions.NewFSEventField(map[string]interface{}{
+		"tags":   []string{"a", "b"},
+		"labels": map[string]string{"env": "test"},
+		"count":  uint16(7),
+		"small":  int8(-3),
+		"team":   Team{Name: "red", Level: 2},
+		"ref":    client.Doc("teams/red"),
+		"none":   (*Team)(nil),
+	})
+
+	value := field.Value().(map[string]interface{})
+	if tags := value["tags"].([]interface{}); len(tags) != 2 || tags[1] != "b" {
+		t.Errorf("tags: %v", value["tags"])
+	} else if value["labels"].(map[string]interface{})["env"] != "test" {
+		t.Errorf("labels: %v", value["labels"])
+	} else if value["count"] != int64(7) || value["small"] != int64(-3) {
+		t.Errorf("integers: %v %v", value["count"], value["small"])
+	} else if team := value["team"].(map[string]interface{}); !reflect.DeepEqual(team, map[string]interface{}{"name": "red", "Level": int64(2)}) {
+		t.Errorf("team: %v", team)
+	} else if value["ref"] != "projects/test/databases/(default)/documents/teams/red" {
+		t.Errorf("ref: %v", value["ref"])
+	} else if !field.AsMap.Fields["none"].IsNull {
+		t.Errorf("none: %v", value["none"])
+	}
+
+	if _, err := functions.ToFSEventField(map[int]string{1: "a"}); err == nil {
+		t.Error("the map with int keys must return an error")
+	} else if _, err := functions.ToFSEventField(make(chan int)); err == nil {
+		t.Error("the channel must return an error")
+	}
+}
+
+type fsAudit struct {
+	Joined  time.Time `firestore:"joined"`
+	Deleted *string   `firestore:"deleted,omitempty"`
+}
+
+type fsUser struct {
+	fsAudit
+	Name   string                 `firestore:"name"`
+	Active bool                   `firestore:"active"`
+	Score  int64                  `firestore:"score"`
+	Ratio  float32                `firestore:"ratio"`
+	Avatar []byte                 `firestore:"avatar"`
+	Team   *firestore.DocumentRef `firestore:"team"`
+	Home   *latlng.LatLng         `firestore:"home"`
+	Tags   []interface{}          `firestore:"tags"`
+	Stats  struct {
+		Wins   int `firestore:"wins"`
+		Nested struct {
+			OK bool `firestore:"ok"`
+		} `firestore:"nested"`
+	} `firestore:"stats"`
+	Ignored string `firestore:"-"`
+}
+
+func TestFSEventValueDataTo(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	defer os.Unsetenv("FIRESTORE_EMULATOR_HOST")
+	client, err := firestore.NewClient(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var event functions.FSEvent
+	if err := json.Unmarshal([]byte(fsEventPayload), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	var user fsUser
+	if err := event.Value.DataToWithClients(&user, &firebase.Clients{Firestore: client}); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Name != "Alice" || !user.Active || user.Score != 9007199254740993 || user.Ratio != 0.25 {
+		t.Errorf("scalars: %+v", user)
+	} else if string(user.Avatar) != "hello" || user.Deleted != nil {
+		t.Errorf("avatar/deleted: %+v", user)
+	} else if !user.Joined.Equal(time.Date(2020, 11, 20, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("joined: %v", user.Joined)
+	} else if user.Team == nil || user.Team.ID != "red" || user.Team.Parent.ID != "teams" {
+		t.Errorf("team: %v", user.Team)
+	} else if user.Home == nil || user.Home.Latitude != 47.5 {
+		t.Errorf("home: %v", user.Home)
+	} else if len(user.Tags) != 2 || user.Tags[1] != int64(2) {
+		t.Errorf("tags: %v", user.Tags)
+	} else if user.Stats.Wins != 3 || user.Stats.Nested.OK {
+		t.Errorf("stats: %+v", user.Stats)
+	}
+}
+
+func TestFSEventValueDataToReference(t *testing.T) {
+	var event functions.FSEvent
+	if err := json.Unmarshal([]byte(fsEventPayload), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := event.Value.DataTo(&data); err != nil {
+		t.Fatal(err)
+	} else if data["team"] != "projects/test/databases/(default)/documents/teams/red" {
+		t.Errorf("team: %v", data["team"])
+	}
+
+	ctx := context.Background()
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	client, err := firestore.NewClient(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var dest struct {
+		Team *firestore.DocumentRef `firestore:"team"`
+	}
+	if err := event.Value.DataToWithClients(&dest, &firebase.Clients{Firestore: client}); err == nil {
+		t.Error("the reference of another project must return an error")
+	}
+	local, err := firestore.NewClient(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	data = nil
+	if err := event.Value.DataToWithClients(&data, &firebase.Clients{Firestore: local}); err != nil {
+		t.Fatal(err)
+	} else if ref, ok := data["team"].(*firestore.DocumentRef); !ok || ref.ID != "red" {
+		t.Errorf("team with client: %v", data["team"])
+	}
+}
+
+func TestFSEventValueDataToMismatch(t *testing.T) {
+	var event functions.FSEvent
+	if err := json.Unmarshal([]byte(fsEventPayload), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	var dest struct {
+		Name int `firestore:"name"`
+	}
+	if err := event.Value.DataTo(&dest); err == nil {
+		t.Error("type mismatch must return an error")
+	}
+
+	var overflow struct {
+		Score int32 `firestore:"score"`
+	}
+	if err := event.Value.DataTo(&overflow); err == nil {
+		t.Error("overflow must return an error")
+	}
+
+	if err := event.Value.DataTo(dest); err == nil {
+		t.Error("non-pointer destination must return an error")
+	}
+}
+
+func TestFSEventChanges(t *testing.T) {
+	mock := functions.MockEvent{
+		Old: map[string]interface{}{
+			"name": "Alice", "score": 1, "stats": map[string]interface{}{"wins": 1, "losses": 2},
+		},
+		New: map[string]interface{}{
+			"name": "Alice", "score": 2, "stats": map[string]interface{}{"wins": 1, "losses": 3},
+			"last.seen": "today",
+		},
+		Resource: "projects/test/databases/(default)/documents/users/alice",
+		Trigger:  functions.FSTriggerWrite,
+	}
+	ctx, event := mock.CreateContext(context.Background())
+
+	want := []string{"`last.seen`", "score", "stats.losses"}
+	if got := event.ChangedFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v != %v", got, want)
+	} else if op, err := event.Operation(ctx); err != nil || op != functions.FSTriggerUpdate {
+		t.Errorf("operation: %v %v", op, err)
+	} else if !event.IsUpdate() || event.IsCreate() || event.IsDelete() {
+		t.Error("the event is an update")
+	} else if !event.HasChanged("stats") || event.HasChanged("stats.wins") || !event.HasChanged("`last.seen`") {
+		t.Error("HasChanged is invalid")
+	}
+
+	var before, after int
+	if err := event.BeforeTo("stats.losses", &before); err != nil || before != 2 {
+		t.Errorf("before: %v %v", before, err)
+	} else if err := event.AfterTo("stats.losses", &after); err != nil || after != 3 {
+		t.Errorf("after: %v %v", after, err)
+	} else if err := event.BeforeTo("last.seen", &before); err == nil {
+		t.Error("missing field must return an error")
+	}
+}
+
+func TestFSEventCreate(t *testing.T) {
+	mock := functions.MockEvent{
+		New:      map[string]interface{}{"name": "Bob"},
+		Resource: "projects/test/databases/(default)/documents/users/bob",
+		Trigger:  functions.FSTriggerWrite,
+	}
+	ctx, event := mock.CreateContext(context.Background())
+
+	if op, err := event.Operation(ctx); err != nil || op != functions.FSTriggerCreate {
+		t.Errorf("operation: %v %v", op, err)
+	} else if got := event.ChangedFields(); !reflect.DeepEqual(got, []string{"name"}) {
+		t.Errorf("changed: %v", got)
+	}
+}
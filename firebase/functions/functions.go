@@ -4,15 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"regexp"
 
 	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+	"github.com/balesz/go/firebase/functions/logging"
 )
 
-var rxFirestorePath = regexp.MustCompile(`.*\(default\)/documents/(.*)`)
-
-var rxDatabasePath = regexp.MustCompile(`projects/_/instances/.*/refs/(.*)`)
-
 // InitializeLog initialize log for Cloud Functions
 func InitializeLog() {
 	log.SetFlags(log.Flags() &^ log.Ltime &^ log.Ldate)
@@ -24,9 +21,8 @@ func LogContext(ctx context.Context, event interface{}) {
 	if err != nil {
 		return
 	}
-	log.Println("Metadata: ", meta)
-	log.Println("Resource: ", meta.Resource)
-	log.Println("Event: ", event)
+	logging.FromMetadata(ctx).Debug("Context",
+		"metadata", fmt.Sprint(meta), "resource", fmt.Sprint(meta.Resource), "event", fmt.Sprint(event))
 }
 
 // GetMetadata get Cloud Functions Metadata from context
@@ -35,19 +31,33 @@ func GetMetadata(ctx context.Context) (meta *metadata.Metadata, err error) {
 	return
 }
 
-// GetPath gets the path of the resource
+// GetPath gets the path of the resource, Realtime Database paths have leading slash
 func GetPath(ctx context.Context) (string, error) {
-	meta, err := metadata.FromContext(ctx)
+	name, err := GetResourceName(ctx)
 	if err != nil {
 		return "", err
 	}
-	var path string
-	if rxFirestorePath.MatchString(meta.Resource.RawPath) {
-		path = rxFirestorePath.ReplaceAllString(meta.Resource.RawPath, "$1")
-	} else if rxDatabasePath.MatchString(meta.Resource.RawPath) {
-		path = rxDatabasePath.ReplaceAllString(meta.Resource.RawPath, "/$1")
-	} else {
-		return "", fmt.Errorf("Invalid Metadata.Resource.RawPath")
+	return name.LocalPath(), nil
+}
+
+// checkTrigger checks that the event type of the context is one of the given triggers
+func checkTrigger(ctx context.Context, triggers ...string) error {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, trigger := range triggers {
+		if meta.EventType == trigger {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unexpected event type (%v)", meta.EventType)
+}
+
+// projectID gets the project of the running function by firebase.ProjectID, "_" if it is not set
+func projectID() string {
+	if project := firebase.ProjectID(); project != "" {
+		return project
 	}
-	return path, nil
+	return "_"
 }
@@ -0,0 +1,274 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	eventStatusDone       = "done"
+	eventStatusProcessing = "processing"
+)
+
+// DefaultEventLease is the default time while an attempt exclusively processes an
+// event, it should be longer than the timeout of the function
+const DefaultEventLease = 10 * time.Minute
+
+// ErrEventInProgress is returned if another attempt holds the lease of the event
+var ErrEventInProgress = errors.New("The event is processed by another attempt")
+
+// EventStore records the processed events of the background functions
+type EventStore interface {
+	// Begin records the event as processing for the lease. It returns false if the
+	// event is already processed and its record is not expired, and ErrEventInProgress
+	// if another attempt holds a lease which is not expired.
+	Begin(ctx context.Context, eventID string, lease time.Duration) (bool, error)
+	// Complete marks the event as processed, the record expires after the ttl
+	Complete(ctx context.Context, eventID string, ttl time.Duration) error
+	// Abort removes the record of the event, so the retry can process it again
+	Abort(ctx context.Context, eventID string) error
+}
+
+// Idempotent skips the events which are already processed by the handler
+type Idempotent struct {
+	// Lease is the time while an attempt exclusively processes the event, the default is DefaultEventLease
+	Lease time.Duration
+	Store EventStore
+	// TTL is the time while the processed events are skipped
+	TTL time.Duration
+}
+
+// NewIdempotent creates a new Idempotent with the given store and record TTL
+func NewIdempotent(store EventStore, ttl time.Duration) Idempotent {
+	return Idempotent{Lease: DefaultEventLease, Store: store, TTL: ttl}
+}
+
+// Run runs the handler once for the event of the context, the event is identified
+// by metadata.EventID and the record is removed if the handler fails. It returns
+// ErrEventInProgress while another attempt holds the lease, so the event is retried.
+func (idem Idempotent) Run(ctx context.Context, handler func(ctx context.Context) error) error {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("metadata.FromContext: %v", err)
+	} else if meta.EventID == "" {
+		return fmt.Errorf("Metadata.EventID is empty")
+	} else if idem.TTL <= 0 {
+		return fmt.Errorf("The TTL of the processed events must be positive")
+	}
+
+	lease := idem.Lease
+	if lease <= 0 {
+		lease = DefaultEventLease
+	}
+
+	if ok, err := idem.Store.Begin(ctx, meta.EventID, lease); err == ErrEventInProgress {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("store.Begin: %v", err)
+	} else if !ok {
+		return nil
+	}
+
+	if err := handler(ctx); err != nil {
+		if er := idem.Store.Abort(ctx, meta.EventID); er != nil {
+			return fmt.Errorf("%v (store.Abort: %v)", err, er)
+		}
+		return err
+	}
+
+	if err := idem.Store.Complete(ctx, meta.EventID, idem.TTL); err != nil {
+		return fmt.Errorf("store.Complete: %v", err)
+	}
+	return nil
+}
+
+// FSHandler wraps the Firestore trigger handler
+func (idem Idempotent) FSHandler(handler FSHandler) FSHandler {
+	return func(ctx context.Context, event FSEvent) error {
+		return idem.Run(ctx, func(ctx context.Context) error { return handler(ctx, event) })
+	}
+}
+
+// RTDBHandler wraps the Realtime Database trigger handler
+func (idem Idempotent) RTDBHandler(handler RTDBHandler) RTDBHandler {
+	return func(ctx context.Context, event RTDBEvent) error {
+		return idem.Run(ctx, func(ctx context.Context) error { return handler(ctx, event) })
+	}
+}
+
+// ProcessedEvent is the type of the event records of FirestoreEventStore, the
+// expire time is the end of the lease while processing and the end of the TTL when done
+type ProcessedEvent struct {
+	CreateTime time.Time `firestore:"createTime,serverTimestamp"`
+	EventID    string    `firestore:"eventID"`
+	ExpireTime time.Time `firestore:"expireTime"`
+	Status     string    `firestore:"status"`
+}
+
+// begin decides whether the event can be processed by the existing record
+func (event ProcessedEvent) begin(now time.Time) (bool, error) {
+	if !now.Before(event.ExpireTime) {
+		return true, nil
+	} else if event.Status == eventStatusProcessing {
+		return false, ErrEventInProgress
+	}
+	return false, nil
+}
+
+// FirestoreEventStore records the events in a Firestore collection, the
+// expireTime field can be used by a Firestore TTL policy to delete old records
+type FirestoreEventStore struct {
+	// Clients are the Firebase clients of the store, the default is firebase.DefaultClients
+	Clients    *firebase.Clients
+	Collection string
+}
+
+// NewFirestoreEventStore creates a new FirestoreEventStore
+func NewFirestoreEventStore(collection string) (*FirestoreEventStore, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("The collection parameter is empty")
+	} else if len(strings.Split(strings.Trim(collection, "/"), "/"))%2 != 1 {
+		return nil, fmt.Errorf("The collection parameter is not a collection path")
+	}
+	return &FirestoreEventStore{Collection: strings.Trim(collection, "/")}, nil
+}
+
+// Begin records the event as processing in a transaction
+func (store FirestoreEventStore) Begin(ctx context.Context, eventID string, lease time.Duration) (bool, error) {
+	client, err := store.Clients.Resolve().GetFirestore(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		ref         = store.doc(client, eventID)
+		maxAttempts = firestore.MaxAttempts(5)
+		began       bool
+	)
+
+	transaction := func(ctx context.Context, tran *firestore.Transaction) error {
+		began = false
+		snap, err := tran.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("tran.Get: %v", err)
+		}
+
+		if snap.Exists() {
+			var event ProcessedEvent
+			if err := snap.DataTo(&event); err != nil {
+				return fmt.Errorf("snap.DataTo: %v", err)
+			} else if ok, err := event.begin(time.Now()); !ok || err != nil {
+				return err
+			}
+		}
+
+		began = true
+		return tran.Set(ref, ProcessedEvent{
+			EventID:    eventID,
+			ExpireTime: time.Now().Add(lease),
+			Status:     eventStatusProcessing,
+		})
+	}
+
+	if err := client.RunTransaction(ctx, transaction, maxAttempts); err == ErrEventInProgress {
+		return false, err
+	} else if err != nil {
+		return false, fmt.Errorf("RunTransaction: %v", err)
+	}
+	return began, nil
+}
+
+// Complete marks the event as processed
+func (store FirestoreEventStore) Complete(ctx context.Context, eventID string, ttl time.Duration) error {
+	client, err := store.Clients.Resolve().GetFirestore(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = store.doc(client, eventID).Update(ctx, []firestore.Update{
+		{Path: "expireTime", Value: time.Now().Add(ttl)},
+		{Path: "status", Value: eventStatusDone},
+	})
+	return err
+}
+
+// Abort removes the record of the event
+func (store FirestoreEventStore) Abort(ctx context.Context, eventID string) error {
+	client, err := store.Clients.Resolve().GetFirestore(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = store.doc(client, eventID).Delete(ctx)
+	return err
+}
+
+func (store FirestoreEventStore) doc(client *firestore.Client, eventID string) *firestore.DocumentRef {
+	return client.Collection(store.Collection).Doc(strings.ReplaceAll(eventID, "/", "_"))
+}
+
+// MemoryEventStore records the events in memory, it is intended for tests
+type MemoryEventStore struct {
+	events map[string]ProcessedEvent
+	mutex  sync.Mutex
+}
+
+// NewMemoryEventStore creates a new MemoryEventStore
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{events: map[string]ProcessedEvent{}}
+}
+
+// Begin records the event as processing
+func (store *MemoryEventStore) Begin(ctx context.Context, eventID string, lease time.Duration) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if event, ok := store.events[eventID]; ok {
+		if ok, err := event.begin(time.Now()); !ok || err != nil {
+			return false, err
+		}
+	}
+	store.events[eventID] = ProcessedEvent{
+		CreateTime: time.Now(),
+		EventID:    eventID,
+		ExpireTime: time.Now().Add(lease),
+		Status:     eventStatusProcessing,
+	}
+	return true, nil
+}
+
+// Complete marks the event as processed
+func (store *MemoryEventStore) Complete(ctx context.Context, eventID string, ttl time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	event, ok := store.events[eventID]
+	if !ok {
+		return fmt.Errorf("The event is not found (%v)", eventID)
+	}
+	event.ExpireTime = time.Now().Add(ttl)
+	event.Status = eventStatusDone
+	store.events[eventID] = event
+	return nil
+}
+
+// Abort removes the record of the event
+func (store *MemoryEventStore) Abort(ctx context.Context, eventID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.events, eventID)
+	return nil
+}
+
+// IsProcessed reports whether the event is processed successfully
+func (store *MemoryEventStore) IsProcessed(eventID string) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.events[eventID].Status == eventStatusDone
+}
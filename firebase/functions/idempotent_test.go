@@ -0,0 +1,134 @@
+package functions_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+	"github.com/balesz/go/firebase/functions"
+)
+
+func TestIdempotent(t *testing.T) {
+	store := functions.NewMemoryEventStore()
+	idem := functions.NewIdempotent(store, time.Hour)
+	ctx := metadata.NewContext(context.Background(), &metadata.Metadata{EventID: "1234"})
+
+	count := 0
+	handler := idem.FSHandler(func(ctx context.Context, event functions.FSEvent) error {
+		count++
+		if count == 1 {
+			return fmt.Errorf("transient error")
+		}
+		return nil
+	})
+
+	if err := handler(ctx, functions.FSEvent{}); err == nil {
+		t.Error("the error of the handler must be returned")
+	} else if store.IsProcessed("1234") {
+		t.Error("the failed event must not be processed")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := handler(ctx, functions.FSEvent{}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if count != 2 {
+		t.Errorf("the handler is called %v times", count)
+	} else if !store.IsProcessed("1234") {
+		t.Error("the event must be processed")
+	}
+}
+
+func TestIdempotentLease(t *testing.T) {
+	store := functions.NewMemoryEventStore()
+	ctx := metadata.NewContext(context.Background(), &metadata.Metadata{EventID: "1234"})
+
+	if ok, err := store.Begin(ctx, "1234", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("the first attempt must hold the lease (%v, %v)", ok, err)
+	}
+
+	idem := functions.Idempotent{Lease: time.Minute, Store: store, TTL: time.Hour}
+	count := 0
+	handler := func(ctx context.Context) error { count++; return nil }
+
+	if err := idem.Run(ctx, handler); err != functions.ErrEventInProgress {
+		t.Errorf("the error must be ErrEventInProgress while the lease is held (%v)", err)
+	} else if count != 0 {
+		t.Error("the handler must not run while the lease is held")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if err := idem.Run(ctx, handler); err != nil {
+		t.Error(err)
+	} else if count != 1 {
+		t.Error("the handler must run after the lease is expired")
+	} else if !store.IsProcessed("1234") {
+		t.Error("the event must be processed")
+	}
+
+	if err := idem.Run(ctx, handler); err != nil {
+		t.Error(err)
+	} else if count != 1 {
+		t.Error("the processed event must be skipped")
+	}
+
+	if err := (functions.Idempotent{Store: store}).Run(ctx, handler); err == nil {
+		t.Error("the TTL must be positive")
+	}
+}
+
+func TestNewFirestoreEventStore(t *testing.T) {
+	if store, err := functions.NewFirestoreEventStore(""); err == nil || store != nil {
+		t.Error("the empty collection must be rejected")
+	} else if store, err := functions.NewFirestoreEventStore("events/doc"); err == nil || store != nil {
+		t.Error("the document path must be rejected")
+	} else if store, err := functions.NewFirestoreEventStore("/events/"); err != nil {
+		t.Error(err)
+	} else if store.Collection != "events" {
+		t.Errorf("the collection is invalid (%v)", store.Collection)
+	}
+}
+
+func TestFirestoreEventStore(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	store, err := functions.NewFirestoreEventStore("processedEvents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Clients = &firebase.Clients{Firestore: client}
+
+	eventID := fmt.Sprintf("event-%v", time.Now().UnixNano())
+	defer store.Abort(ctx, eventID)
+
+	if ok, err := store.Begin(ctx, eventID, time.Minute); err != nil || !ok {
+		t.Errorf("the first attempt must hold the lease (%v, %v)", ok, err)
+	} else if _, err := store.Begin(ctx, eventID, time.Minute); err != functions.ErrEventInProgress {
+		t.Errorf("the error must be ErrEventInProgress while the lease is held (%v)", err)
+	} else if err := store.Complete(ctx, eventID, time.Hour); err != nil {
+		t.Error(err)
+	} else if ok, err := store.Begin(ctx, eventID, time.Minute); err != nil || ok {
+		t.Errorf("the processed event must be skipped (%v, %v)", ok, err)
+	} else if err := store.Abort(ctx, eventID); err != nil {
+		t.Error(err)
+	} else if ok, err := store.Begin(ctx, eventID, time.Minute); err != nil || !ok {
+		t.Errorf("the aborted event must be processed again (%v, %v)", ok, err)
+	}
+}
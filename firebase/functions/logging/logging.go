@@ -1,31 +1,394 @@
 package logging
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+)
+
+// Severity is the severity of the log entry
+type Severity string
+
+// Severities of Cloud Logging in increasing order
+const (
+	SeverityDefault   Severity = "DEFAULT"
+	SeverityDebug     Severity = "DEBUG"
+	SeverityInfo      Severity = "INFO"
+	SeverityNotice    Severity = "NOTICE"
+	SeverityWarning   Severity = "WARNING"
+	SeverityError     Severity = "ERROR"
+	SeverityCritical  Severity = "CRITICAL"
+	SeverityAlert     Severity = "ALERT"
+	SeverityEmergency Severity = "EMERGENCY"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityDefault: 0, SeverityDebug: 100, SeverityInfo: 200, SeverityNotice: 300, SeverityWarning: 400,
+	SeverityError: 500, SeverityCritical: 600, SeverityAlert: 700, SeverityEmergency: 800,
+}
+
+const loggerContextKey = contextKey("logger")
+
+type contextKey string
+
+var rxTraceContext = regexp.MustCompile(`^([0-9a-fA-F]+)(?:/([0-9]+))?`)
+
+var (
+	config = struct {
+		sync.RWMutex
+		level Severity
+		sink  Sink
+	}{level: SeverityDefault, sink: NewStdoutSink()}
 )
 
-//Debug -
+func init() {
+	if level, err := ParseSeverity(os.Getenv("LOG_LEVEL")); err == nil {
+		SetLevel(level)
+	}
+}
+
+// ParseSeverity parses the case-insensitive name of the severity
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(strings.ToUpper(strings.TrimSpace(name)))
+	if _, ok := severityRanks[severity]; !ok {
+		return SeverityDefault, fmt.Errorf("Unknown severity (%v)", name)
+	}
+	return severity, nil
+}
+
+// Enabled reports whether the severity reaches the minimum severity
+func (severity Severity) Enabled() bool {
+	config.RLock()
+	defer config.RUnlock()
+	return severityRanks[severity] >= severityRanks[config.level]
+}
+
+// SetLevel sets the minimum severity of the written entries, the default is
+// SeverityDefault or the value of the LOG_LEVEL environment variable
+func SetLevel(severity Severity) {
+	config.Lock()
+	defer config.Unlock()
+	config.level = severity
+}
+
+// SetSink sets the sink of the entries, the default is the standard output
+func SetSink(sink Sink) {
+	config.Lock()
+	defer config.Unlock()
+	config.sink = sink
+}
+
+// Logger writes structured log entries recognized by Cloud Logging
+type Logger struct {
+	fields      map[string]interface{}
+	httpRequest *HTTPRequest
+	labels      map[string]string
+	spanID      string
+	trace       string
+}
+
+// HTTPRequest is the httpRequest field of the log entry
+type HTTPRequest struct {
+	Latency       string `json:"latency,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestSize   string `json:"requestSize,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	ResponseSize  string `json:"responseSize,omitempty"`
+	Status        int    `json:"status,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+}
+
+// SourceLocation is the sourceLocation field of the log entry
+type SourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Function string `json:"function,omitempty"`
+	Line     string `json:"line,omitempty"`
+}
+
+// New creates a new Logger without fields
+func New() *Logger {
+	return &Logger{}
+}
+
+// NewHTTPRequest creates the httpRequest field from the request
+func NewHTTPRequest(r *http.Request) *HTTPRequest {
+	req := &HTTPRequest{
+		Protocol:      r.Proto,
+		Referer:       r.Referer(),
+		RemoteIP:      RemoteIP(r, DefaultTrustedProxies),
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		UserAgent:     r.UserAgent(),
+	}
+	if r.ContentLength > 0 {
+		req.RequestSize = strconv.FormatInt(r.ContentLength, 10)
+	}
+	return req
+}
+
+// DefaultTrustedProxies is the number of the proxies which append the address of their
+// client to X-Forwarded-For, it is the Google front end of Cloud Functions and Cloud Run
+const DefaultTrustedProxies = 1
+
+// RemoteIP gets the IP address of the client, it is the hop of X-Forwarded-For appended by
+// the first of the trusted proxies, the hops before it are set by the client and not trusted.
+// It is the remote address without trusted proxies or if X-Forwarded-For has fewer hops.
+func RemoteIP(r *http.Request, trustedProxies int) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" && trustedProxies > 0 {
+		if hops := strings.Split(forwarded, ","); len(hops) >= trustedProxies {
+			return strings.TrimSpace(hops[len(hops)-trustedProxies])
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// FromRequest creates a Logger with the trace of the X-Cloud-Trace-Context header and the httpRequest field,
+// the decimal span of the header is converted to the 16 digit hexadecimal spanId of Cloud Logging
+func FromRequest(r *http.Request) *Logger {
+	logger := New().WithHTTPRequest(NewHTTPRequest(r))
+	if match := rxTraceContext.FindStringSubmatch(r.Header.Get("X-Cloud-Trace-Context")); match != nil {
+		var spanID string
+		if span, err := strconv.ParseUint(match[2], 10, 64); err == nil {
+			spanID = fmt.Sprintf("%016x", span)
+		}
+		logger = logger.WithTrace(match[1], spanID)
+	}
+	return logger
+}
+
+// FromMetadata creates a Logger labeled with the event of the Cloud Functions metadata
+func FromMetadata(ctx context.Context) *Logger {
+	logger := New()
+	if meta, err := metadata.FromContext(ctx); err == nil {
+		labels := map[string]string{"eventId": meta.EventID, "eventType": meta.EventType}
+		if meta.Resource != nil {
+			labels["resource"] = meta.Resource.RawPath
+		}
+		logger = logger.WithLabels(labels)
+	}
+	return logger
+}
+
+// NewContext returns a copy of the context carrying the logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// FromContext gets the logger of the context, it falls back to the metadata of Cloud Functions
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*Logger); ok && logger != nil {
+		return logger
+	}
+	return FromMetadata(ctx)
+}
+
+// With returns a copy of the logger with the given key/value pairs as fields
+func (logger *Logger) With(keyvals ...interface{}) *Logger {
+	clone := logger.clone()
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 < len(keyvals) {
+			clone.fields[key] = keyvals[i+1]
+		} else {
+			clone.fields[key] = nil
+		}
+	}
+	return clone
+}
+
+// WithLabels returns a copy of the logger with the given labels
+func (logger *Logger) WithLabels(labels map[string]string) *Logger {
+	clone := logger.clone()
+	for key, val := range labels {
+		clone.labels[key] = val
+	}
+	return clone
+}
+
+// WithTrace returns a copy of the logger with the trace and the hexadecimal span ID
+func (logger *Logger) WithTrace(traceID string, spanID string) *Logger {
+	clone := logger.clone()
+	if traceID != "" {
+		if project := firebase.ProjectID(); project != "" {
+			clone.trace = fmt.Sprintf("projects/%v/traces/%v", project, traceID)
+		} else {
+			clone.trace = traceID
+		}
+	}
+	clone.spanID = spanID
+	return clone
+}
+
+// WithHTTPRequest returns a copy of the logger with the httpRequest field
+func (logger *Logger) WithHTTPRequest(req *HTTPRequest) *Logger {
+	clone := logger.clone()
+	clone.httpRequest = req
+	return clone
+}
+
+// Debug writes a DEBUG entry with the given key/value pairs
+func (logger *Logger) Debug(message string, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityDebug, message, 2)
+}
+
+// Info writes an INFO entry with the given key/value pairs
+func (logger *Logger) Info(message string, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityInfo, message, 2)
+}
+
+// Warning writes a WARNING entry with the given key/value pairs
+func (logger *Logger) Warning(message string, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityWarning, message, 2)
+}
+
+// Error writes an ERROR entry of the error with the given key/value pairs
+func (logger *Logger) Error(err error, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityError, err.Error(), 2)
+}
+
+// Notice writes a NOTICE entry with the given key/value pairs
+func (logger *Logger) Notice(message string, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityNotice, message, 2)
+}
+
+// Critical writes a CRITICAL entry of the error with the given key/value pairs
+func (logger *Logger) Critical(err error, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityCritical, err.Error(), 2)
+}
+
+// Alert writes an ALERT entry of the error with the given key/value pairs
+func (logger *Logger) Alert(err error, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityAlert, err.Error(), 2)
+}
+
+// Emergency writes an EMERGENCY entry of the error with the given key/value pairs
+func (logger *Logger) Emergency(err error, keyvals ...interface{}) {
+	logger.With(keyvals...).write(SeverityEmergency, err.Error(), 2)
+}
+
+// Log writes an entry with the given severity
+func (logger *Logger) Log(severity Severity, message string, keyvals ...interface{}) {
+	logger.With(keyvals...).write(severity, message, 2)
+}
+
+// Debug writes a DEBUG entry
 func Debug(message string) {
-	printLog(message, "debug")
+	New().write(SeverityDebug, message, 2)
 }
 
-//Error -
+// Error writes an ERROR entry of the error
 func Error(err error) {
-	printLog(err.Error(), "error")
+	New().write(SeverityError, err.Error(), 2)
 }
 
-//Info -
+// Info writes an INFO entry
 func Info(message string) {
-	printLog(message, "info")
+	New().write(SeverityInfo, message, 2)
 }
 
-//Warning -
+// Warning writes a WARNING entry
 func Warning(message string) {
-	printLog(message, "warning")
+	New().write(SeverityWarning, message, 2)
+}
+
+// Notice writes a NOTICE entry
+func Notice(message string) {
+	New().write(SeverityNotice, message, 2)
+}
+
+// Critical writes a CRITICAL entry of the error
+func Critical(err error) {
+	New().write(SeverityCritical, err.Error(), 2)
+}
+
+// Alert writes an ALERT entry of the error
+func Alert(err error) {
+	New().write(SeverityAlert, err.Error(), 2)
+}
+
+// Emergency writes an EMERGENCY entry of the error
+func Emergency(err error) {
+	New().write(SeverityEmergency, err.Error(), 2)
+}
+
+func (logger *Logger) clone() *Logger {
+	clone := &Logger{fields: map[string]interface{}{}, labels: map[string]string{}}
+	if logger == nil {
+		return clone
+	}
+	for key, val := range logger.fields {
+		clone.fields[key] = val
+	}
+	for key, val := range logger.labels {
+		clone.labels[key] = val
+	}
+	clone.httpRequest = logger.httpRequest
+	clone.spanID = logger.spanID
+	clone.trace = logger.trace
+	return clone
+}
+
+func (logger *Logger) entry(severity Severity, message string, skip int) Entry {
+	entry := Entry{}
+	if logger != nil {
+		for key, val := range logger.fields {
+			if err, ok := val.(error); ok {
+				val = err.Error()
+			}
+			entry[key] = val
+		}
+		if len(logger.labels) > 0 {
+			entry["logging.googleapis.com/labels"] = logger.labels
+		}
+		if logger.trace != "" {
+			entry["logging.googleapis.com/trace"] = logger.trace
+		}
+		if logger.spanID != "" {
+			entry["logging.googleapis.com/spanId"] = logger.spanID
+		}
+		if logger.httpRequest != nil {
+			entry["httpRequest"] = logger.httpRequest
+		}
+	}
+	if pc, file, line, ok := runtime.Caller(skip); ok {
+		location := SourceLocation{File: file, Line: strconv.Itoa(line)}
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			location.Function = fn.Name()
+		}
+		entry["logging.googleapis.com/sourceLocation"] = location
+	}
+	entry["message"] = message
+	entry["severity"] = severity
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	return entry
 }
 
-func printLog(message string, severity string) {
-	msg := strings.ReplaceAll(message, "\"", "'")
-	fmt.Println(fmt.Sprintf("{\"message\": \"%v\", \"severity\": \"%v\"}", msg, severity))
+func (logger *Logger) write(severity Severity, message string, skip int) {
+	if !severity.Enabled() {
+		return
+	}
+	entry := logger.entry(severity, message, skip+1)
+	config.RLock()
+	sink := config.sink
+	config.RUnlock()
+	if err := sink.Write(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "logging: %v\n", err)
+	}
 }
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	SetSink(NewWriterSink(&buffer))
+	defer SetSink(NewStdoutSink())
+
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "game")
+	defer os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+
+	r := httptest.NewRequest("POST", "/score", nil)
+	r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1234567890123456789;o=1")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+
+	logger := FromRequest(r).WithLabels(map[string]string{"env": "test"})
+	logger.Warning("say \"hello\"\n\\world", "uid", "alice", "err", fmt.Errorf("failed"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	location := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if entry["message"] != "say \"hello\"\n\\world" || entry["severity"] != "WARNING" {
+		t.Errorf("entry: %v", entry)
+	} else if entry["uid"] != "alice" || entry["err"] != "failed" {
+		t.Errorf("fields: %v", entry)
+	} else if entry["logging.googleapis.com/trace"] != "projects/game/traces/105445aa7843bc8bf206b12000100000" {
+		t.Errorf("trace: %v", entry["logging.googleapis.com/trace"])
+	} else if entry["logging.googleapis.com/spanId"] != "112210f47de98115" {
+		t.Errorf("spanId: %v", entry["logging.googleapis.com/spanId"])
+	} else if entry["logging.googleapis.com/labels"].(map[string]interface{})["env"] != "test" {
+		t.Errorf("labels: %v", entry["logging.googleapis.com/labels"])
+	} else if entry["httpRequest"].(map[string]interface{})["requestMethod"] != "POST" {
+		t.Errorf("httpRequest: %v", entry["httpRequest"])
+	} else if entry["httpRequest"].(map[string]interface{})["remoteIp"] != "203.0.113.7" {
+		t.Errorf("remoteIp: %v", entry["httpRequest"])
+	} else if !strings.HasSuffix(location["file"].(string), "logging_test.go") {
+		t.Errorf("sourceLocation: %v", location)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	sink := NewMemorySink()
+	SetSink(sink)
+	defer SetSink(NewStdoutSink())
+	SetLevel(SeverityWarning)
+	defer SetLevel(SeverityDefault)
+
+	Debug("debug")
+	Info("info")
+	Notice("notice")
+	Warning("warning")
+	New().Critical(fmt.Errorf("critical"), "uid", "alice")
+
+	entries := sink.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("entries: %v", entries)
+	} else if entries[0].Message() != "warning" || entries[0].Severity() != SeverityWarning {
+		t.Errorf("entry: %v", entries[0])
+	} else if entries[1].Severity() != SeverityCritical || entries[1]["uid"] != "alice" {
+		t.Errorf("entry: %v", entries[1])
+	}
+
+	if severity, err := ParseSeverity("notice"); err != nil || severity != SeverityNotice {
+		t.Errorf("ParseSeverity: %v %v", severity, err)
+	} else if _, err := ParseSeverity("verbose"); err == nil {
+		t.Error("unknown severity must return an error")
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", nil)
+	r.RemoteAddr = "10.0.0.9:1234"
+	if ip := RemoteIP(r, 1); ip != "10.0.0.9" {
+		t.Errorf("without header: %v", ip)
+	}
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7, 10.0.0.1")
+	if ip := RemoteIP(r, 1); ip != "10.0.0.1" {
+		t.Errorf("one proxy: %v", ip)
+	} else if ip := RemoteIP(r, 2); ip != "203.0.113.7" {
+		t.Errorf("two proxies: %v", ip)
+	} else if ip := RemoteIP(r, 4); ip != "10.0.0.9" {
+		t.Errorf("fewer hops: %v", ip)
+	} else if ip := RemoteIP(r, 0); ip != "10.0.0.9" {
+		t.Errorf("no proxies: %v", ip)
+	}
+}
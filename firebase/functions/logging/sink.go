@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Entry is a structured log entry
+type Entry map[string]interface{}
+
+// Message gets the message of the entry
+func (entry Entry) Message() string {
+	message, _ := entry["message"].(string)
+	return message
+}
+
+// Severity gets the severity of the entry
+func (entry Entry) Severity() Severity {
+	severity, _ := entry["severity"].(Severity)
+	return severity
+}
+
+// Sink receives the log entries
+type Sink interface {
+	Write(entry Entry) error
+}
+
+// WriterSink writes the entries as JSON lines to the writer
+type WriterSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewWriterSink creates a new WriterSink
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+// NewStdoutSink creates a WriterSink writing to the standard output
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// Write encodes the entry and writes it as a line
+func (sink *WriterSink) Write(entry Entry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		encoded, _ = json.Marshal(Entry{
+			"message":  fmt.Sprintf("%v (json.Marshal: %v)", entry.Message(), err),
+			"severity": entry.Severity(),
+		})
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, err = sink.writer.Write(append(encoded, '\n'))
+	return err
+}
+
+// FileSink appends the entries as JSON lines to a local file
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+// NewFileSink opens or creates the file for appending
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %v", err)
+	}
+	return &FileSink{WriterSink: NewWriterSink(file), file: file}, nil
+}
+
+// Close closes the file
+func (sink *FileSink) Close() error {
+	return sink.file.Close()
+}
+
+// MemorySink keeps the entries in memory for assertions in tests
+type MemorySink struct {
+	entries []Entry
+	mutex   sync.Mutex
+}
+
+// NewMemorySink creates a new MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write appends the entry
+func (sink *MemorySink) Write(entry Entry) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.entries = append(sink.entries, entry)
+	return nil
+}
+
+// Entries gets a copy of the written entries
+func (sink *MemorySink) Entries() []Entry {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return append([]Entry{}, sink.entries...)
+}
+
+// Reset removes the written entries
+func (sink *MemorySink) Reset() {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.entries = nil
+}
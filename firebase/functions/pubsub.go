@@ -0,0 +1,81 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+// PubSubTriggerPublish : Triggered when a message is published to the topic.
+const PubSubTriggerPublish = "google.pubsub.topic.publish"
+
+// PubSubTriggerPublishLegacy : The legacy event type of PubSubTriggerPublish.
+const PubSubTriggerPublishLegacy = "providers/cloud.pubsub/eventTypes/topic.publish"
+
+// PubSubMessage is the payload of a Pub/Sub trigger, Data is decoded from base64
+type PubSubMessage struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Data       []byte            `json:"data"`
+}
+
+func (msg PubSubMessage) String() string {
+	return fmt.Sprintf("PubSubMessage(Attributes: %v, Data: %v)", msg.Attributes, msg.Text())
+}
+
+// Text gets the data of the message as string
+func (msg PubSubMessage) Text() string {
+	return string(msg.Data)
+}
+
+// DataTo decodes the JSON data of the message to the given type
+func (msg PubSubMessage) DataTo(dest interface{}) error {
+	if err := json.Unmarshal(msg.Data, dest); err != nil {
+		return fmt.Errorf("DataTo: %v", err)
+	}
+	return nil
+}
+
+// ParsePubSubMessage decodes the payload of a Pub/Sub trigger
+func ParsePubSubMessage(ctx context.Context, payload []byte) (msg PubSubMessage, err error) {
+	if err = checkTrigger(ctx, PubSubTriggerPublish, PubSubTriggerPublishLegacy); err != nil {
+		return
+	} else if err = json.Unmarshal(payload, &msg); err != nil {
+		err = fmt.Errorf("ParsePubSubMessage: %v", err)
+	}
+	return
+}
+
+// MockPubSubMessage MockPubSubMessage
+type MockPubSubMessage struct {
+	Attributes map[string]string
+	Data       interface{}
+	Topic      string
+}
+
+// CreateContext creates context for testing, Data is JSON encoded unless it is string or []byte
+func (evnt *MockPubSubMessage) CreateContext(base context.Context) (ctx context.Context, msg PubSubMessage) {
+	msg = PubSubMessage{Attributes: evnt.Attributes}
+	switch data := evnt.Data.(type) {
+	case []byte:
+		msg.Data = data
+	case string:
+		msg.Data = []byte(data)
+	default:
+		msg.Data, _ = json.Marshal(data)
+	}
+	ctx = metadata.NewContext(base, &metadata.Metadata{
+		EventID:   time.Now().UTC().Format(time.RFC3339),
+		EventType: PubSubTriggerPublish,
+		Resource: &metadata.Resource{
+			Name:    fmt.Sprintf("projects/%v/topics/%v", projectID(), evnt.Topic),
+			RawPath: fmt.Sprintf("projects/%v/topics/%v", projectID(), evnt.Topic),
+			Service: "pubsub.googleapis.com",
+			Type:    "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+		},
+		Timestamp: time.Now(),
+	})
+	return
+}
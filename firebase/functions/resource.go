@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/balesz/go/firebase"
+)
+
+// ServiceDatabase is the service of Realtime Database resource names
+const ServiceDatabase = "firebaseio.com"
+
+// ServiceFirestore is the service of Firestore resource names
+const ServiceFirestore = "firestore.googleapis.com"
+
+// FSDefaultDatabase is the ID of the default Firestore database
+const FSDefaultDatabase = "(default)"
+
+// ResourceName is the parsed resource of a Firestore or Realtime Database trigger
+type ResourceName struct {
+	// Database is the Firestore database ID or the Realtime Database instance name
+	Database string
+	// Path is the document or reference path without leading slash
+	Path string
+	// Project is the project ID, it is "_" for Realtime Database
+	Project string
+	// Service is ServiceFirestore or ServiceDatabase
+	Service string
+}
+
+// ParseResourceName parses a Firestore or a Realtime Database resource name
+func ParseResourceName(raw string) (name ResourceName, err error) {
+	parts := strings.Split(strings.Trim(raw, "/"), "/")
+	if len(parts) >= 5 && parts[0] == "projects" && parts[2] == "databases" && parts[4] == "documents" {
+		name = ResourceName{
+			Database: parts[3],
+			Path:     strings.Join(parts[5:], "/"),
+			Project:  parts[1],
+			Service:  ServiceFirestore,
+		}
+	} else if len(parts) >= 5 && parts[0] == "projects" && parts[2] == "instances" && parts[4] == "refs" {
+		name = ResourceName{
+			Database: parts[3],
+			Path:     strings.Join(parts[5:], "/"),
+			Project:  parts[1],
+			Service:  ServiceDatabase,
+		}
+	} else {
+		err = fmt.Errorf("Invalid resource name (%v)", raw)
+		return
+	}
+	if name.Project == "" || name.Database == "" {
+		err = fmt.Errorf("Invalid resource name (%v)", raw)
+	}
+	return
+}
+
+// NewFirestoreResourceName creates the resource name of a Firestore document,
+// the default database is used if database is empty
+func NewFirestoreResourceName(project string, database string, path string) ResourceName {
+	if database == "" {
+		database = FSDefaultDatabase
+	}
+	return ResourceName{
+		Database: database, Path: strings.Trim(path, "/"), Project: project, Service: ServiceFirestore,
+	}
+}
+
+// NewDatabaseResourceName creates the resource name of a Realtime Database reference
+func NewDatabaseResourceName(instance string, path string) ResourceName {
+	return ResourceName{
+		Database: instance, Path: strings.Trim(path, "/"), Project: "_", Service: ServiceDatabase,
+	}
+}
+
+// GetResourceName gets the parsed resource name of the trigger from the context
+func GetResourceName(ctx context.Context) (ResourceName, error) {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return ResourceName{}, err
+	} else if meta.Resource == nil {
+		return ResourceName{}, fmt.Errorf("Metadata.Resource is missing")
+	}
+	return ParseResourceName(meta.Resource.RawPath)
+}
+
+func (name ResourceName) String() string {
+	if name.Service == ServiceDatabase {
+		return fmt.Sprintf("projects/%v/instances/%v/refs/%v", name.Project, name.Database, name.Path)
+	}
+	return fmt.Sprintf("projects/%v/databases/%v/documents/%v", name.Project, name.Database, name.Path)
+}
+
+// IsFirestore reports whether the resource is a Firestore document
+func (name ResourceName) IsFirestore() bool {
+	return name.Service == ServiceFirestore
+}
+
+// IsDatabase reports whether the resource is a Realtime Database reference
+func (name ResourceName) IsDatabase() bool {
+	return name.Service == ServiceDatabase
+}
+
+// IsDefaultDatabase reports whether the resource is in the default Firestore database
+func (name ResourceName) IsDefaultDatabase() bool {
+	return name.Service == ServiceFirestore && name.Database == FSDefaultDatabase
+}
+
+// Collection gets the path of the parent collection or the parent reference
+func (name ResourceName) Collection() string {
+	if index := strings.LastIndex(name.Path, "/"); index >= 0 {
+		return name.Path[:index]
+	}
+	return ""
+}
+
+// CollectionID gets the ID of the parent collection or the key of the parent reference
+func (name ResourceName) CollectionID() string {
+	collection := name.Collection()
+	return collection[strings.LastIndex(collection, "/")+1:]
+}
+
+// DocumentID gets the ID of the document or the key of the reference
+func (name ResourceName) DocumentID() string {
+	return name.Path[strings.LastIndex(name.Path, "/")+1:]
+}
+
+// LocalPath gets the path used by the clients, Realtime Database paths have leading slash
+func (name ResourceName) LocalPath() string {
+	if name.Service == ServiceDatabase {
+		return "/" + name.Path
+	}
+	return name.Path
+}
+
+// DocPath gets the path of the Firestore document
+func (name ResourceName) DocPath() (firebase.DocPath, error) {
+	return firebase.ParseDocPath(name.Path)
+}
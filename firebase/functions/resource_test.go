@@ -0,0 +1,47 @@
+package functions_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/balesz/go/firebase/functions"
+)
+
+func TestParseResourceName(t *testing.T) {
+	raw := "projects/game/databases/eu-db/documents/users/alice/orders/o1"
+	if name, err := functions.ParseResourceName(raw); err != nil {
+		t.Error(err)
+	} else if name.Project != "game" || name.Database != "eu-db" || name.IsDefaultDatabase() {
+		t.Errorf("name: %+v", name)
+	} else if name.Path != "users/alice/orders/o1" || name.Collection() != "users/alice/orders" {
+		t.Errorf("path: %+v", name)
+	} else if name.CollectionID() != "orders" || name.DocumentID() != "o1" || name.String() != raw {
+		t.Errorf("ids: %+v", name)
+	} else if path, err := name.DocPath(); err != nil || path.Parent().String() != name.Collection() {
+		t.Errorf("doc path: %v %v", path, err)
+	}
+
+	raw = "projects/_/instances/game-eu/refs/status/bob"
+	if name, err := functions.ParseResourceName(raw); err != nil {
+		t.Error(err)
+	} else if !name.IsDatabase() || name.Database != "game-eu" || name.LocalPath() != "/status/bob" {
+		t.Errorf("name: %+v", name)
+	} else if name.String() != functions.NewDatabaseResourceName("game-eu", "/status/bob").String() {
+		t.Errorf("format: %v", name)
+	}
+
+	if _, err := functions.ParseResourceName("projects/game/topics/jobs"); err == nil {
+		t.Error("invalid resource name must return an error")
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	mock := functions.MockEvent{
+		Resource: functions.NewFirestoreResourceName("game", "eu-db", "users/alice").String(),
+		Trigger:  functions.FSTriggerCreate,
+	}
+	ctx, _ := mock.CreateContext(context.Background())
+	if path, err := functions.GetPath(ctx); err != nil || path != "users/alice" {
+		t.Errorf("path: %v %v", path, err)
+	}
+}
@@ -0,0 +1,170 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+const paramsContextKey = contextKey("params")
+
+type contextKey string
+
+// Params contains the wildcard values of the matched path template
+type Params map[string]string
+
+// Get gets the value of the wildcard
+func (params Params) Get(name string) string {
+	return params[name]
+}
+
+// GetParams gets the params of the matched path template from the context
+func GetParams(ctx context.Context) Params {
+	if params, ok := ctx.Value(paramsContextKey).(Params); ok {
+		return params
+	}
+	return Params{}
+}
+
+// FSHandler handles a Firestore trigger
+type FSHandler func(ctx context.Context, event FSEvent) error
+
+// RTDBHandler handles a Realtime Database trigger
+type RTDBHandler func(ctx context.Context, event RTDBEvent) error
+
+// Router dispatches Firestore and Realtime Database triggers by path template and event type
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	database    bool
+	fsHandler   FSHandler
+	rtdbHandler RTDBHandler
+	segments    []string
+	template    string
+	trigger     string
+}
+
+// NewRouter creates a new Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Firestore registers a handler for the FSTrigger* event type and the document
+// path template (e.g. users/{uid}/orders/{orderId})
+func (router *Router) Firestore(trigger string, template string, handler FSHandler) *Router {
+	router.routes = append(router.routes, route{
+		fsHandler: handler, segments: splitPath(template), template: template, trigger: trigger,
+	})
+	return router
+}
+
+// Database registers a handler for the RTDBTrigger* event type and the reference path template
+func (router *Router) Database(trigger string, template string, handler RTDBHandler) *Router {
+	router.routes = append(router.routes, route{
+		database: true, rtdbHandler: handler, segments: splitPath(template), template: template, trigger: trigger,
+	})
+	return router
+}
+
+// Handle is the entry point of the Cloud Function, it decodes the payload and invokes the matching handler
+func (router *Router) Handle(ctx context.Context, payload json.RawMessage) error {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("metadata.FromContext: %v", err)
+	} else if meta.Resource == nil {
+		return fmt.Errorf("Metadata.Resource is missing")
+	}
+
+	path, err := GetPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	var fsEvent FSEvent
+	var rtdbEvent RTDBEvent
+	var operation string
+
+	database := strings.HasPrefix(meta.EventType, "providers/google.firebase.database/")
+	if database {
+		if err := json.Unmarshal(payload, &rtdbEvent); err != nil {
+			return fmt.Errorf("json.Unmarshal: %v", err)
+		}
+		operation = rtdbOperation(meta.EventType, rtdbEvent)
+	} else {
+		if err := json.Unmarshal(payload, &fsEvent); err != nil {
+			return fmt.Errorf("json.Unmarshal: %v", err)
+		}
+		operation, _ = fsEvent.Operation(ctx)
+	}
+
+	for _, route := range router.routes {
+		if route.database != database || !route.matchTrigger(meta.EventType, operation) {
+			continue
+		}
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		ctx = context.WithValue(ctx, paramsContextKey, params)
+		if database {
+			return route.rtdbHandler(ctx, rtdbEvent)
+		}
+		return route.fsHandler(ctx, fsEvent)
+	}
+
+	return fmt.Errorf("No handler is registered for %v (%v)", meta.EventType, path)
+}
+
+// matchTrigger reports whether the route handles the event type, write routes handle
+// every change and specific routes handle the classified changes of write events
+func (route route) matchTrigger(eventType string, operation string) bool {
+	switch {
+	case route.trigger == eventType:
+		return true
+	case route.trigger == FSTriggerWrite || route.trigger == RTDBTriggerWrite:
+		return true
+	case eventType == FSTriggerWrite || eventType == RTDBTriggerWrite:
+		return route.trigger == operation
+	}
+	return false
+}
+
+func (route route) match(path string) (Params, bool) {
+	segments := splitPath(path)
+	if len(segments) != len(route.segments) {
+		return nil, false
+	}
+	params := Params{}
+	for i, segment := range route.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[strings.Trim(segment, "{}")] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func rtdbOperation(eventType string, event RTDBEvent) string {
+	if eventType != RTDBTriggerWrite {
+		return eventType
+	} else if event.Data == nil {
+		return RTDBTriggerCreate
+	} else if event.After() == nil {
+		return RTDBTriggerDelete
+	}
+	return RTDBTriggerUpdate
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
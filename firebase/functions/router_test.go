@@ -0,0 +1,71 @@
+package functions_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/balesz/go/firebase/functions"
+)
+
+func TestRouter(t *testing.T) {
+	var called string
+	var params functions.Params
+
+	router := functions.NewRouter().
+		Firestore(functions.FSTriggerCreate, "users/{uid}/orders/{orderId}", func(ctx context.Context, event functions.FSEvent) error {
+			called, params = "order.create", functions.GetParams(ctx)
+			return nil
+		}).
+		Firestore(functions.FSTriggerWrite, "users/{uid}", func(ctx context.Context, event functions.FSEvent) error {
+			called, params = "user.write", functions.GetParams(ctx)
+			return nil
+		}).
+		Database(functions.RTDBTriggerDelete, "/status/{uid}", func(ctx context.Context, event functions.RTDBEvent) error {
+			called, params = "status.delete", functions.GetParams(ctx)
+			return nil
+		})
+
+	mock := functions.MockEvent{
+		New:      map[string]interface{}{"total": 10},
+		Resource: "projects/test/databases/(default)/documents/users/alice/orders/o1",
+		Trigger:  functions.FSTriggerWrite,
+	}
+	ctx, event := mock.CreateContext(context.Background())
+	payload, _ := json.Marshal(event)
+	if err := router.Handle(ctx, payload); err != nil {
+		t.Error(err)
+	} else if called != "order.create" || params.Get("uid") != "alice" || params.Get("orderId") != "o1" {
+		t.Errorf("%v %v", called, params)
+	}
+
+	mock.Resource = "projects/test/databases/(default)/documents/users/alice"
+	mock.Trigger = functions.FSTriggerUpdate
+	ctx, event = mock.CreateContext(context.Background())
+	payload, _ = json.Marshal(event)
+	if err := router.Handle(ctx, payload); err != nil {
+		t.Error(err)
+	} else if called != "user.write" || params.Get("uid") != "alice" {
+		t.Errorf("%v %v", called, params)
+	}
+
+	rtdb := functions.MockRTDBEvent{
+		Old:      map[string]interface{}{"online": true},
+		Resource: "projects/_/instances/test/refs/status/bob",
+		Trigger:  functions.RTDBTriggerWrite,
+	}
+	ctx, rtdbEvent := rtdb.CreateContext(context.Background())
+	payload, _ = json.Marshal(rtdbEvent)
+	if err := router.Handle(ctx, payload); err != nil {
+		t.Error(err)
+	} else if called != "status.delete" || params.Get("uid") != "bob" {
+		t.Errorf("%v %v", called, params)
+	}
+
+	mock.Resource = "projects/test/databases/(default)/documents/teams/red"
+	ctx, event = mock.CreateContext(context.Background())
+	payload, _ = json.Marshal(event)
+	if err := router.Handle(ctx, payload); err == nil {
+		t.Error("unmatched path must return an error")
+	}
+}
@@ -0,0 +1,98 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+// StorageTriggerArchive : Triggered when a live version of an object is archived or deleted.
+const StorageTriggerArchive = "google.storage.object.archive"
+
+// StorageTriggerDelete : Triggered when an object is permanently deleted.
+const StorageTriggerDelete = "google.storage.object.delete"
+
+// StorageTriggerFinalize : Triggered when a new object is successfully created in the bucket.
+const StorageTriggerFinalize = "google.storage.object.finalize"
+
+// StorageTriggerMetadataUpdate : Triggered when the metadata of an existing object changes.
+const StorageTriggerMetadataUpdate = "google.storage.object.metadataUpdate"
+
+// StorageObjectEvent is the object metadata of a Cloud Storage trigger
+type StorageObjectEvent struct {
+	Bucket             string            `json:"bucket"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ContentLanguage    string            `json:"contentLanguage,omitempty"`
+	ContentType        string            `json:"contentType"`
+	CRC32C             string            `json:"crc32c"`
+	Generation         int64             `json:"generation,string"`
+	ID                 string            `json:"id"`
+	MD5Hash            string            `json:"md5Hash"`
+	MediaLink          string            `json:"mediaLink"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Metageneration     int64             `json:"metageneration,string"`
+	Name               string            `json:"name"`
+	SelfLink           string            `json:"selfLink"`
+	Size               int64             `json:"size,string"`
+	StorageClass       string            `json:"storageClass"`
+	TimeCreated        time.Time         `json:"timeCreated"`
+	TimeDeleted        *time.Time        `json:"timeDeleted,omitempty"`
+	Updated            time.Time         `json:"updated"`
+}
+
+func (event StorageObjectEvent) String() string {
+	format := "StorageObjectEvent(Bucket: %v, Name: %v, Size: %v, ContentType: %v, Metadata: %v)"
+	return fmt.Sprintf(format, event.Bucket, event.Name, event.Size, event.ContentType, event.Metadata)
+}
+
+// ParseStorageObjectEvent decodes the payload of a Cloud Storage trigger
+func ParseStorageObjectEvent(ctx context.Context, payload []byte) (event StorageObjectEvent, err error) {
+	triggers := []string{
+		StorageTriggerArchive, StorageTriggerDelete, StorageTriggerFinalize, StorageTriggerMetadataUpdate,
+	}
+	if err = checkTrigger(ctx, triggers...); err != nil {
+		return
+	} else if err = json.Unmarshal(payload, &event); err != nil {
+		err = fmt.Errorf("ParseStorageObjectEvent: %v", err)
+	}
+	return
+}
+
+// MockStorageObjectEvent MockStorageObjectEvent
+type MockStorageObjectEvent struct {
+	Object  StorageObjectEvent
+	Trigger string
+}
+
+// CreateContext creates context for testing
+func (evnt *MockStorageObjectEvent) CreateContext(base context.Context) (ctx context.Context, event StorageObjectEvent) {
+	event = evnt.Object
+	if event.TimeCreated.IsZero() {
+		event.TimeCreated = time.Now()
+		event.Updated = event.TimeCreated
+	}
+	if event.Generation == 0 {
+		event.Generation = event.TimeCreated.UnixNano() / int64(time.Microsecond)
+		event.Metageneration = 1
+	}
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%v/%v/%v", event.Bucket, event.Name, event.Generation)
+	}
+	ctx = metadata.NewContext(base, &metadata.Metadata{
+		EventID:   time.Now().UTC().Format(time.RFC3339),
+		EventType: evnt.Trigger,
+		Resource: &metadata.Resource{
+			Name:    fmt.Sprintf("projects/_/buckets/%v/objects/%v", event.Bucket, event.Name),
+			RawPath: fmt.Sprintf("projects/_/buckets/%v/objects/%v", event.Bucket, event.Name),
+			Service: "storage.googleapis.com",
+			Type:    "storage#object",
+		},
+		Timestamp: time.Now(),
+	})
+	return
+}
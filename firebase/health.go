@@ -0,0 +1,194 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "firebase.google.com/go/v4/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// healthCheckID is the ID of the documents and users read by the health checks, they need not exist
+const healthCheckID = "__health_check__"
+
+// healthNotInitialized is the status of the lazy clients which are not used yet
+const healthNotInitialized = "not initialized"
+
+// ClientHealth is the health status of a client, the latency is in nanoseconds in JSON.
+// The lazy clients which are not used yet are healthy with "not initialized" status.
+type ClientHealth struct {
+	Error   string        `json:"error,omitempty"`
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency"`
+	Status  string        `json:"status,omitempty"`
+}
+
+// HealthReport is the health status of the clients, it is not healthy without the Firebase app
+type HealthReport struct {
+	Clients map[string]ClientHealth `json:"clients"`
+	Error   string                  `json:"error,omitempty"`
+	Healthy bool                    `json:"healthy"`
+}
+
+// healthProbe checks a client with a cheap call
+type healthProbe func(ctx context.Context) error
+
+// Close closes the clients, the closed clients are removed
+func (clients *Clients) Close() error {
+	clients.lock()
+	defer clients.unlock()
+	var err error
+	if clients.Firestore != nil {
+		if er := clients.Firestore.Close(); er != nil {
+			err = fmt.Errorf("Firestore.Close: %v", er)
+		}
+	}
+	clients.Auth, clients.Database, clients.Firestore = nil, nil, nil
+	if clients.isDefault {
+		App, Auth, Database, Firestore = nil, nil, nil, nil
+	}
+	return err
+}
+
+// Close closes the clients of the package-level variables, InitializeClients may be called again
+func Close() error {
+	return DefaultClients().Close()
+}
+
+// HealthCheck probes the initialized clients with a cheap call concurrently, the
+// lazy clients which are not used yet are not initialized by the health check and
+// they are reported as healthy. The report is not healthy without the Firebase app.
+func (clients *Clients) HealthCheck(ctx context.Context) HealthReport {
+	probes, lazy := clients.healthProbes()
+	if len(probes) == 0 && len(lazy) == 0 {
+		return HealthReport{Clients: map[string]ClientHealth{}, Error: "The Firebase app is not initialized"}
+	}
+	report := checkHealth(ctx, probes)
+	for _, kind := range lazy {
+		report.Clients[kind] = ClientHealth{Healthy: true, Status: healthNotInitialized}
+	}
+	return report
+}
+
+// healthProbes gets the probes of the initialized clients and the kinds of the
+// lazy clients of the app, the Database is lazy only if its URL is configured
+func (clients *Clients) healthProbes() (map[string]healthProbe, []string) {
+	clients.lock()
+	defer clients.unlock()
+	probes := map[string]healthProbe{}
+	var lazy []string
+	if client := clients.Auth; client != nil {
+		probes[ClientAuth] = func(ctx context.Context) error {
+			if _, err := client.GetUser(ctx, healthCheckID); err != nil && !auth.IsUserNotFound(err) {
+				return err
+			}
+			return nil
+		}
+	} else if clients.App != nil {
+		lazy = append(lazy, ClientAuth)
+	}
+	if client := clients.Database; client != nil {
+		probes[ClientDatabase] = func(ctx context.Context) error {
+			var value interface{}
+			return client.NewRef(healthCheckID).Get(ctx, &value)
+		}
+	} else if clients.App != nil && clients.config != nil && clients.config.DatabaseURL != "" {
+		lazy = append(lazy, ClientDatabase)
+	}
+	if client := clients.Firestore; client != nil {
+		probes[ClientFirestore] = func(ctx context.Context) error {
+			if _, err := client.Collection(healthCheckID).Doc(healthCheckID).Get(ctx); err != nil && status.Code(err) != codes.NotFound {
+				return err
+			}
+			return nil
+		}
+	} else if clients.App != nil {
+		lazy = append(lazy, ClientFirestore)
+	}
+	return probes, lazy
+}
+
+// checkHealth runs the probes concurrently, the probes which are not finished
+// when the context is done are reported as unhealthy with the error of the context
+func checkHealth(ctx context.Context, probes map[string]healthProbe) HealthReport {
+	report := HealthReport{Clients: map[string]ClientHealth{}, Healthy: true}
+
+	type result struct {
+		health ClientHealth
+		kind   string
+	}
+	start := time.Now()
+	results := make(chan result, len(probes))
+	for kind, probe := range probes {
+		go func(kind string, probe healthProbe) {
+			start := time.Now()
+			err := probe(ctx)
+			health := ClientHealth{Healthy: err == nil, Latency: time.Since(start)}
+			if err != nil {
+				health.Error = err.Error()
+			}
+			results <- result{health: health, kind: kind}
+		}(kind, probe)
+	}
+
+	for len(report.Clients) < len(probes) {
+		select {
+		case result := <-results:
+			report.Clients[result.kind] = result.health
+			report.Healthy = report.Healthy && result.health.Healthy
+		case <-ctx.Done():
+			for kind := range probes {
+				if _, ok := report.Clients[kind]; !ok {
+					report.Clients[kind] = ClientHealth{Error: ctx.Err().Error(), Latency: time.Since(start)}
+				}
+			}
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+// HealthCheck probes the clients of the package-level variables
+func HealthCheck(ctx context.Context) HealthReport {
+	return DefaultClients().HealthCheck(ctx)
+}
+
+// HealthHandler serves the health report of the clients as JSON for readiness
+// probes, the status is 503 if any client is unhealthy, the Firebase app is not
+// initialized or the timeout is exceeded. The clients may be nil for the default clients.
+func HealthHandler(clients *Clients, timeout time.Duration) http.Handler {
+	return healthHandler(func(ctx context.Context) HealthReport {
+		return clients.Resolve().HealthCheck(ctx)
+	}, timeout)
+}
+
+func healthHandler(check func(ctx context.Context) HealthReport, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		report := check(ctx)
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(encoded)
+	})
+}
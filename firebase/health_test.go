@@ -0,0 +1,70 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	serve := func(check func(ctx context.Context) HealthReport) (int, HealthReport) {
+		w := httptest.NewRecorder()
+		healthHandler(check, 50*time.Millisecond).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		var report HealthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Error(err)
+		}
+		return w.Code, report
+	}
+	probes := func(probes map[string]healthProbe) func(ctx context.Context) HealthReport {
+		return func(ctx context.Context) HealthReport { return checkHealth(ctx, probes) }
+	}
+	healthy := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return fmt.Errorf("unavailable") }
+	blocking := func(ctx context.Context) error { time.Sleep(time.Second); return nil }
+
+	if code, report := serve(probes(map[string]healthProbe{ClientAuth: healthy, ClientFirestore: healthy})); code != 200 || !report.Healthy {
+		t.Errorf("healthy: %v %+v", code, report)
+	} else if code, report := serve(probes(map[string]healthProbe{ClientAuth: healthy, ClientFirestore: failing})); code != 503 || report.Healthy {
+		t.Errorf("failing: %v %+v", code, report)
+	} else if report.Clients[ClientFirestore].Error != "unavailable" || !report.Clients[ClientAuth].Healthy {
+		t.Errorf("failing clients: %+v", report.Clients)
+	} else if code, report := serve(probes(map[string]healthProbe{ClientAuth: healthy, ClientDatabase: blocking})); code != 503 || report.Healthy {
+		t.Errorf("timeout: %v %+v", code, report)
+	} else if report.Clients[ClientDatabase].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("timeout clients: %+v", report.Clients)
+	}
+
+	w := httptest.NewRecorder()
+	HealthHandler(&Clients{}, time.Second).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 503 {
+		t.Errorf("no app: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestHealthCheckLazyClients(t *testing.T) {
+	t.Setenv("FIREBASE_CONFIG", `{"projectId": "demo-test", "databaseURL": "https://demo-test.firebaseio.com"}`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv(AuthEmulatorHost, "localhost:9099")
+	t.Setenv(DatabaseEmulatorHost, "localhost:9000")
+	t.Setenv(FirestoreEmulatorHost, "localhost:8080")
+
+	clients, err := NewClients(context.Background(), WithEagerClients())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	HealthHandler(clients, time.Second).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var report HealthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	} else if w.Code != 200 || !report.Healthy || len(report.Clients) != 3 {
+		t.Errorf("lazy clients: %v %+v", w.Code, report)
+	} else if health := report.Clients[ClientFirestore]; !health.Healthy || health.Status != healthNotInitialized {
+		t.Errorf("lazy firestore: %+v", health)
+	}
+}
@@ -0,0 +1,241 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	firestore "cloud.google.com/go/firestore"
+	database "firebase.google.com/go/v4/db"
+)
+
+// Errors of the segment count of the paths
+var (
+	ErrNotCollectionPath = errors.New("The path is not a collection path")
+	ErrNotDocumentPath   = errors.New("The path is not a document path")
+)
+
+// rxReservedID matches the IDs reserved by Firestore
+var rxReservedID = regexp.MustCompile(`^__.*__$`)
+
+// maxSegmentBytes is the maximum size of the Firestore document IDs
+const maxSegmentBytes = 1500
+
+// DocPath is a validated Firestore document path with even number of segments
+type DocPath struct {
+	segments []string
+}
+
+// CollectionPath is a validated Firestore collection path with odd number of segments
+type CollectionPath struct {
+	segments []string
+}
+
+// NewDocPath creates a document path from the segments (e.g. "users", uid)
+func NewDocPath(segments ...string) (DocPath, error) {
+	if err := validateSegments(segments); err != nil {
+		return DocPath{}, err
+	} else if len(segments)%2 != 0 {
+		return DocPath{}, fmt.Errorf("%w (%v)", ErrNotDocumentPath, strings.Join(segments, "/"))
+	}
+	return DocPath{segments: append([]string{}, segments...)}, nil
+}
+
+// NewCollectionPath creates a collection path from the segments (e.g. "users", uid, "orders")
+func NewCollectionPath(segments ...string) (CollectionPath, error) {
+	if err := validateSegments(segments); err != nil {
+		return CollectionPath{}, err
+	} else if len(segments)%2 != 1 {
+		return CollectionPath{}, fmt.Errorf("%w (%v)", ErrNotCollectionPath, strings.Join(segments, "/"))
+	}
+	return CollectionPath{segments: append([]string{}, segments...)}, nil
+}
+
+// ParseDocPath parses a slash separated document path, the path is literal even if
+// it looks like a resource name, see ParseDocPathFromResource
+func ParseDocPath(path string) (DocPath, error) {
+	return NewDocPath(splitPath(path)...)
+}
+
+// ParseCollectionPath parses a slash separated collection path, the path is literal
+// even if it looks like a resource name, see ParseCollectionPathFromResource
+func ParseCollectionPath(path string) (CollectionPath, error) {
+	return NewCollectionPath(splitPath(path)...)
+}
+
+// ParseDocPathFromResource parses the document path of a Firestore or Realtime Database
+// resource name (e.g. projects/{project}/databases/(default)/documents/users/{uid})
+func ParseDocPathFromResource(name string) (DocPath, error) {
+	segments, err := splitResourceName(name)
+	if err != nil {
+		return DocPath{}, err
+	}
+	return NewDocPath(segments...)
+}
+
+// ParseCollectionPathFromResource parses the collection path of a Firestore or Realtime Database resource name
+func ParseCollectionPathFromResource(name string) (CollectionPath, error) {
+	segments, err := splitResourceName(name)
+	if err != nil {
+		return CollectionPath{}, err
+	}
+	return NewCollectionPath(segments...)
+}
+
+func (path DocPath) String() string {
+	return strings.Join(path.segments, "/")
+}
+
+// Segments gets a copy of the segments of the path
+func (path DocPath) Segments() []string {
+	return append([]string{}, path.segments...)
+}
+
+// ID gets the document ID
+func (path DocPath) ID() string {
+	if len(path.segments) == 0 {
+		return ""
+	}
+	return path.segments[len(path.segments)-1]
+}
+
+// IsZero reports whether the path is the zero value
+func (path DocPath) IsZero() bool {
+	return len(path.segments) == 0
+}
+
+// Parent gets the collection of the document
+func (path DocPath) Parent() CollectionPath {
+	if len(path.segments) == 0 {
+		return CollectionPath{}
+	}
+	return CollectionPath{segments: path.segments[: len(path.segments)-1 : len(path.segments)-1]}
+}
+
+// Collection gets the subcollection of the document
+func (path DocPath) Collection(id string) (CollectionPath, error) {
+	return NewCollectionPath(append(path.Segments(), id)...)
+}
+
+// Ref gets the document of the Firestore client
+func (path DocPath) Ref(client *firestore.Client) *firestore.DocumentRef {
+	return client.Doc(path.String())
+}
+
+// DBRef gets the reference of the Realtime Database client, it fails if a
+// segment contains characters which are forbidden in Realtime Database keys
+func (path DocPath) DBRef(client *database.Client) (*database.Ref, error) {
+	return dbRef(client, path.segments)
+}
+
+func (path CollectionPath) String() string {
+	return strings.Join(path.segments, "/")
+}
+
+// Segments gets a copy of the segments of the path
+func (path CollectionPath) Segments() []string {
+	return append([]string{}, path.segments...)
+}
+
+// ID gets the collection ID
+func (path CollectionPath) ID() string {
+	if len(path.segments) == 0 {
+		return ""
+	}
+	return path.segments[len(path.segments)-1]
+}
+
+// IsZero reports whether the path is the zero value
+func (path CollectionPath) IsZero() bool {
+	return len(path.segments) == 0
+}
+
+// Parent gets the document of the subcollection, it is false for root collections
+func (path CollectionPath) Parent() (DocPath, bool) {
+	if len(path.segments) < 2 {
+		return DocPath{}, false
+	}
+	return DocPath{segments: path.segments[: len(path.segments)-1 : len(path.segments)-1]}, true
+}
+
+// Doc gets the document of the collection
+func (path CollectionPath) Doc(id string) (DocPath, error) {
+	return NewDocPath(append(path.Segments(), id)...)
+}
+
+// Ref gets the collection of the Firestore client
+func (path CollectionPath) Ref(client *firestore.Client) *firestore.CollectionRef {
+	return client.Collection(path.String())
+}
+
+// DBRef gets the reference of the Realtime Database client, it fails if a
+// segment contains characters which are forbidden in Realtime Database keys
+func (path CollectionPath) DBRef(client *database.Client) (*database.Ref, error) {
+	return dbRef(client, path.segments)
+}
+
+// ValidateDatabaseKey checks the key of a Realtime Database path, it must not
+// be empty or contain . # $ [ ] / or ASCII control characters
+func ValidateDatabaseKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("The database key is empty")
+	} else if len(key) > 768 {
+		return fmt.Errorf("The database key is longer than 768 bytes (%v)", key)
+	}
+	for _, char := range key {
+		if strings.ContainsRune(".#$[]/", char) || char < 32 || char == 127 {
+			return fmt.Errorf("The database key contains forbidden character %q (%v)", char, key)
+		}
+	}
+	return nil
+}
+
+func dbRef(client *database.Client, segments []string) (*database.Ref, error) {
+	for _, segment := range segments {
+		if err := ValidateDatabaseKey(segment); err != nil {
+			return nil, err
+		}
+	}
+	return client.NewRef("/" + strings.Join(segments, "/")), nil
+}
+
+// validateSegments checks the Firestore IDs of the path
+func validateSegments(segments []string) error {
+	if len(segments) == 0 {
+		return fmt.Errorf("The path is empty")
+	}
+	for _, segment := range segments {
+		switch {
+		case segment == "":
+			return fmt.Errorf("The path contains empty segment (%v)", strings.Join(segments, "/"))
+		case strings.Contains(segment, "/"):
+			return fmt.Errorf("The path segment contains slash (%v)", segment)
+		case segment == "." || segment == "..":
+			return fmt.Errorf("The path segment is invalid (%v)", segment)
+		case rxReservedID.MatchString(segment):
+			return fmt.Errorf("The path segment is reserved (%v)", segment)
+		case len(segment) > maxSegmentBytes:
+			return fmt.Errorf("The path segment is longer than %v bytes", maxSegmentBytes)
+		}
+	}
+	return nil
+}
+
+// splitPath splits the path, leading and trailing slashes are kept as empty segments
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// splitResourceName splits the path of the Firestore or Realtime Database resource name
+func splitResourceName(name string) ([]string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 5 || parts[0] != "projects" ||
+		!((parts[2] == "databases" && parts[4] == "documents") || (parts[2] == "instances" && parts[4] == "refs")) {
+		return nil, fmt.Errorf("The resource name is invalid (%v)", name)
+	}
+	return parts[5:], nil
+}
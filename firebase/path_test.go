@@ -0,0 +1,65 @@
+package firebase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDocPath(t *testing.T) {
+	path, err := NewDocPath("users", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := path.Collection("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := orders.Doc("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path.String() != "users/alice" || path.ID() != "alice" || path.Parent().String() != "users" {
+		t.Errorf("path: %v", path)
+	} else if order.String() != "users/alice/orders/1" || order.Parent().ID() != "orders" {
+		t.Errorf("order: %v", order)
+	} else if parent, ok := orders.Parent(); !ok || parent.String() != "users/alice" {
+		t.Errorf("parent: %v %v", parent, ok)
+	} else if _, ok := path.Parent().Parent(); ok {
+		t.Errorf("root collection has parent")
+	}
+
+	resource := "projects/game/databases/(default)/documents/users/alice"
+	if parsed, err := ParseDocPathFromResource(resource); err != nil || !reflect.DeepEqual(parsed, path) {
+		t.Errorf("resource: %v %v", parsed, err)
+	} else if parsed, err := ParseCollectionPathFromResource("projects/_/instances/game/refs/users"); err != nil || parsed.String() != "users" {
+		t.Errorf("database resource: %v %v", parsed, err)
+	} else if _, err := ParseDocPathFromResource("users/alice"); err == nil {
+		t.Errorf("path as resource name")
+	} else if parsed, err := ParseDocPath("projects/p1/databases/d1/documents/x/y/z"); err != nil || parsed.String() != "projects/p1/databases/d1/documents/x/y/z" {
+		t.Errorf("literal path: %v %v", parsed, err)
+	}
+
+	for _, invalid := range []string{"", "/users/alice", "users/alice/", "users/..", "users/__id__", "users//alice"} {
+		if _, err := ParseDocPath(invalid); err == nil || errors.Is(err, ErrNotDocumentPath) {
+			t.Errorf("invalid %q: %v", invalid, err)
+		}
+	}
+	if _, err := ParseDocPath("users"); !errors.Is(err, ErrNotDocumentPath) {
+		t.Errorf("collection: %v", err)
+	} else if _, err := ParseCollectionPath("users/alice"); !errors.Is(err, ErrNotCollectionPath) {
+		t.Errorf("document: %v", err)
+	} else if _, err := NewDocPath("users", "a/b"); err == nil {
+		t.Errorf("slash in segment")
+	}
+
+	if err := ValidateDatabaseKey("alice"); err != nil {
+		t.Error(err)
+	}
+	for _, invalid := range []string{"a.b", "a#b", "a$b", "a[b", "a]b", ""} {
+		if err := ValidateDatabaseKey(invalid); err == nil {
+			t.Errorf("database key %q", invalid)
+		}
+	}
+}